@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+)
+
+var adminMu sync.Mutex // 同一时间只修改一次配置文件
+
+// adminAuth 检查请求头 Authorization: Bearer <token>，没有设置令牌时管理接口不可用
+func adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if *AdminToken == "" {
+			http.Error(w, `{"success":false,"msg":"Admin API disabled, set EPOS_ADMIN_TOKEN or -token-file"}`, http.StatusForbidden)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(*AdminToken)) != 1 {
+			http.Error(w, `{"success":false,"msg":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// adminListPrinters GET /admin/printers 返回所有打印机的配置
+func adminListPrinters(w http.ResponseWriter, r *http.Request) {
+	configPrinters, err := eprinter.ReadConfig(*ConfigFile)
+	if err != nil {
+		writeAdminResult(w, http.StatusInternalServerError, "Failed to read config: "+err.Error(), nil)
+		return
+	}
+	writeAdminResult(w, http.StatusOK, "", configPrinters)
+}
+
+// adminGetPrinter GET /admin/printers/{name} 返回一台打印机的配置
+func adminGetPrinter(w http.ResponseWriter, r *http.Request) {
+	configPrinters, err := eprinter.ReadConfig(*ConfigFile)
+	if err != nil {
+		writeAdminResult(w, http.StatusInternalServerError, "Failed to read config: "+err.Error(), nil)
+		return
+	}
+	config, ok := configPrinters[r.PathValue("name")]
+	if !ok {
+		writeAdminResult(w, http.StatusNotFound, "Printer not found", nil)
+		return
+	}
+	writeAdminResult(w, http.StatusOK, "", config)
+}
+
+// adminPutPrinter PUT /admin/printers/{name} 添加或修改打印机，POST只能添加
+func adminPutPrinter(w http.ResponseWriter, r *http.Request) {
+	var config eprinter.ConfigPrinter
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		writeAdminResult(w, http.StatusBadRequest, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+	name := r.PathValue("name")
+	updateConfig(w, func(configPrinters map[string]eprinter.ConfigPrinter) (int, string) {
+		if _, exists := configPrinters[name]; exists && r.Method == http.MethodPost {
+			return http.StatusConflict, "Printer already exists"
+		}
+		configPrinters[name] = config
+		return http.StatusOK, "Printer " + name + " saved"
+	})
+}
+
+// adminDeletePrinter DELETE /admin/printers/{name} 删除打印机
+func adminDeletePrinter(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	updateConfig(w, func(configPrinters map[string]eprinter.ConfigPrinter) (int, string) {
+		if _, exists := configPrinters[name]; !exists {
+			return http.StatusNotFound, "Printer not found"
+		}
+		delete(configPrinters, name)
+		return http.StatusOK, "Printer " + name + " deleted"
+	})
+}
+
+// updateConfig 读取配置文件，修改并检查通过后保存，然后立即重新加载打印机
+func updateConfig(w http.ResponseWriter, modify func(map[string]eprinter.ConfigPrinter) (int, string)) {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	configPrinters, err := eprinter.ReadConfig(*ConfigFile)
+	if err != nil {
+		writeAdminResult(w, http.StatusInternalServerError, "Failed to read config: "+err.Error(), nil)
+		return
+	}
+	status, msg := modify(configPrinters)
+	if status != http.StatusOK {
+		writeAdminResult(w, status, msg, nil)
+		return
+	}
+	if err := eprinter.ValidateConfig(configPrinters); err != nil {
+		writeAdminResult(w, http.StatusBadRequest, "Invalid config: "+err.Error(), nil)
+		return
+	}
+	if err := eprinter.SaveConfig(*ConfigFile, configPrinters); err != nil {
+		writeAdminResult(w, http.StatusInternalServerError, "Failed to save config: "+err.Error(), nil)
+		return
+	}
+	if err := ReloadConfig(*ConfigFile); err != nil {
+		writeAdminResult(w, http.StatusInternalServerError, "Config saved but reload failed: "+err.Error(), nil)
+		return
+	}
+	writeAdminResult(w, http.StatusOK, msg, configPrinters)
+}
+
+func writeAdminResult(w http.ResponseWriter, status int, msg string, printers any) {
+	result := map[string]any{"success": status == http.StatusOK}
+	if msg != "" {
+		result["msg"] = msg
+	}
+	if printers != nil {
+		result["printers"] = printers
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+)
+
+var (
+	printersMu sync.RWMutex // 保护Printers
+	reloadMu   sync.Mutex   // 同一时间只进行一次热加载
+)
+
+// GetPrinters 返回当前的打印机，热加载时整体替换，返回的map不会被修改
+func GetPrinters() eprinter.Printers {
+	printersMu.RLock()
+	defer printersMu.RUnlock()
+	return Printers
+}
+
+// ReloadConfig 重新加载配置文件，配置无效时保留当前的打印机
+// 等待变化的打印机完成正在打印的任务时不影响其他打印机的请求
+func ReloadConfig(filename string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	printers, err := eprinter.ReloadPrinters(filename, GetPrinters())
+	if err != nil {
+		return err
+	}
+	printersMu.Lock()
+	Printers = printers
+	printersMu.Unlock()
+	fmt.Println("Config reloaded, available printers:")
+	for name, printer := range printers {
+		fmt.Println("Printer:", name, printer)
+	}
+	return nil
+}
+
+// WatchConfig 每隔几秒检查配置文件的修改时间，收到SIGHUP时立即重新加载
+func WatchConfig(filename string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+
+	modTime := configModTime(filename)
+	for {
+		select {
+		case <-hup:
+			fmt.Println("Received SIGHUP, reloading config")
+		case <-ticker.C:
+			t := configModTime(filename)
+			if t.Equal(modTime) {
+				continue
+			}
+			modTime = t
+			fmt.Println("Config file changed, reloading")
+		}
+		if err := ReloadConfig(filename); err != nil {
+			fmt.Println("Failed to reload config, keeping current printers:", err)
+		}
+	}
+}
+
+func configModTime(filename string) time.Time {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+)
+
+//go:embed html/dashboard.html
+var DashboardFS embed.FS
+
+// DashboardPrinter 控制台显示的打印机信息
+type DashboardPrinter struct {
+	Name        string         `json:"name"`
+	Type        string         `json:"type"`
+	Address     string         `json:"address,omitempty"`
+	Transformer string         `json:"transformer,omitempty"`
+	Mode        string         `json:"mode,omitempty"`
+	Members     []string       `json:"members,omitempty"`
+	Jobs        []eprinter.Job `json:"jobs"`
+}
+
+// dashboardHandler 返回内嵌的控制台页面
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := DashboardFS.ReadFile("html/dashboard.html")
+	if err != nil {
+		http.Error(w, "Dashboard not found", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page)
+}
+
+// dashboardPrintersHandler GET /dashboard/printers 返回打印机配置和最近的任务，实时状态通过 /eprint/status 查询
+func dashboardPrintersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	printers := GetPrinters()
+	list := make([]DashboardPrinter, 0, len(printers))
+	for name, printer := range printers {
+		info := DashboardPrinter{Name: name, Type: "unknown", Jobs: []eprinter.Job{}}
+		if spool, ok := printer.(*eprinter.SpoolPrinter); ok {
+			config := spool.Config()
+			info.Type = config.Type
+			info.Address = config.Address
+			info.Transformer = config.Transformer
+			info.Mode = config.Mode
+			info.Members = config.Members
+			info.Jobs = spool.Jobs()
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "printers": list})
+}
+
+// dashboardActionHandler POST /dashboard/{action} 执行控制台上的按钮操作
+// test: 打印测试页，pulse: 打开钱箱，reprint: 重新打印x_job指定的任务
+func dashboardActionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.PathValue("action") == "reprint" {
+		jobID := r.URL.Query().Get("x_job")
+		spool, _, ok := eprinter.FindJob(GetPrinters(), jobID)
+		if !ok {
+			http.Error(w, `{"success":false,"msg":"Job not found"}`, http.StatusNotFound)
+			return
+		}
+		id, err := spool.Reprint(jobID)
+		if err != nil {
+			http.Error(w, `{"success":false,"msg":"Failed to reprint job: `+err.Error()+`"}`, http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "msg": "Job submitted", "job_id": id})
+		return
+	}
+
+	printer, ok := GetPrinters()[r.URL.Query().Get("x_printer")]
+	if !ok {
+		http.Error(w, `{"success":false,"msg":"Printer not found"}`, http.StatusBadRequest)
+		return
+	}
+	var err error
+	switch r.PathValue("action") {
+	case "test":
+		err = PrintTestPage(printer)
+	case "pulse":
+		err = printer.OpenCashBox()
+	default:
+		http.Error(w, `{"success":false,"msg":"Unknown action"}`, http.StatusNotFound)
+		return
+	}
+	writePrintResult(w, printer, err, "Done", "Failed")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+)
+
+const defaultDiscoverTimeout = 3 * time.Second // 等待广播响应的时间
+
+// adminDiscoverPrinters GET /admin/discover?x_timeout=3 扫描局域网中的网络打印机，
+// printers 为可以直接添加到config.json的配置，devices 为找到的设备信息
+func adminDiscoverPrinters(w http.ResponseWriter, r *http.Request) {
+	timeout := defaultDiscoverTimeout
+	if seconds, err := strconv.Atoi(r.URL.Query().Get("x_timeout")); err == nil && seconds > 0 {
+		timeout = time.Duration(min(seconds, 30)) * time.Second
+	}
+	devices := eprinter.Discover(timeout)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  true,
+		"printers": discoveredConfig(devices),
+		"devices":  devices,
+	})
+}
+
+// discoveredConfig 返回以建议名称为key的打印机配置
+func discoveredConfig(devices []eprinter.DiscoveredPrinter) map[string]eprinter.ConfigPrinter {
+	configPrinters := make(map[string]eprinter.ConfigPrinter, len(devices))
+	for _, d := range devices {
+		configPrinters[d.Name] = d.Config
+	}
+	return configPrinters
+}
+
+// discoverCommand 命令行子命令 discover，设备列表输出到stderr，配置输出到stdout
+func discoverCommand() {
+	fmt.Fprintln(os.Stderr, "Scanning local network for printers...")
+	devices := eprinter.Discover(defaultDiscoverTimeout)
+	for _, d := range devices {
+		fmt.Fprintf(os.Stderr, "%-21s %-8s %-12s %s\n", d.Address, d.Source, d.Vendor, d.Model)
+	}
+	fmt.Fprintf(os.Stderr, "Found %d printers\n", len(devices))
+	data, _ := json.MarshalIndent(discoveredConfig(devices), "", "    ")
+	fmt.Println(string(data))
+}
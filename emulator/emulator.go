@@ -0,0 +1,110 @@
+package emulator
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// Server 模拟一台网络打印机（端口9100），将每个连接收到的ESC/POS指令绘制为图像，
+// 并像真实打印机一样响应DLE EOT、GS a等状态查询，用于在没有打印机的情况下测试和查看打印效果
+type Server struct {
+	Width   int                       // 纸张宽度（点），默认576
+	Handler func(*raster.RasterImage) // 连接关闭后处理打印结果，只查询状态的连接不会调用
+
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+}
+
+// Listen 在addr上启动模拟打印机，addr为空时使用随机端口
+func Listen(addr string, width int, handler func(*raster.RasterImage)) (*Server, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Width: width, Handler: handler, listener: listener, conns: make(map[net.Conn]bool)}
+	go s.serve()
+	return s, nil
+}
+
+// SaveTo 返回将打印结果保存为png文件的Handler，多页的打印结果每页保存为一个文件
+func SaveTo(dir string) func(*raster.RasterImage) {
+	return func(img *raster.RasterImage) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Println("Emulator failed to create dir:", err)
+			return
+		}
+		name := time.Now().Format("20060102-150405.000")
+		for i, page := range img.CutPages() {
+			filename := filepath.Join(dir, fmt.Sprintf("%s-%d.png", name, i+1))
+			if err := page.SaveToPngFile(filename); err != nil {
+				fmt.Println("Emulator failed to save page:", err)
+			}
+		}
+	}
+}
+
+// Addr 返回模拟打印机的监听地址，可以直接作为tcp打印机的address
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close 停止模拟打印机，关闭所有连接并等待已收到的打印结果处理完
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// handle 读取连接中的指令直到对方关闭连接
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	e := raster.NewEscPosEmulator(s.Width)
+	e.Response = conn
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		e.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	if img := e.Image(); img != nil && s.Handler != nil {
+		s.Handler(img)
+	}
+}
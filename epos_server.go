@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -12,14 +14,12 @@ import (
 	"strings"
 	"time"
 
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
 	"github.com/xiaohao0576/odoo-epos/raster"
 )
 
 const (
-	EPOS_PULSE    = "pulse"
-	EPOS_IMAGE    = "image"
-	EPOS_TEST     = "test"
-	EPOS_RESPONSE = `<response success="true" code="">ok</response>`
+	EPOS_TEST = "test"
 )
 
 var ServerCert []byte
@@ -27,6 +27,10 @@ var ServerKey []byte
 
 // ePOSHandler 处理 ePOS 打印请求
 func ePOShandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/" {
+		dashboardHandler(w, r) // 控制台页面
+		return
+	}
 	if r.Method == http.MethodGet {
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(http.StatusOK)
@@ -56,18 +60,25 @@ func ePOShandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	name := parts[1]
-	printer, ok := Printers[name]
-	if !ok {
-		http.Error(w, "Printer not found", http.StatusInternalServerError)
-		fmt.Println("Printer not found:", name)
-		return
-	}
 
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	printJobID := r.URL.Query().Get("printjobid")
+	copies, err := parseCopies(r.URL.Query().Get("copies"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	printer, ok := GetPrinters()[name]
+	if !ok {
+		fmt.Println("Printer not found:", name)
+		writeEposResponse(w, raster.NewEposResponse(false, raster.EPOS_CODE_DEVICE_NOT_FOUND, raster.ASB_NO_RESPONSE), printJobID)
+		return
+	}
+
 	// 读取请求体
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -78,49 +89,134 @@ func ePOShandler(w http.ResponseWriter, r *http.Request) {
 	// Print the request host IP and port for debugging
 	fmt.Printf("Received ePOS request from %s for printer %s\n", r.RemoteAddr, name)
 
+	eposPrint, err := raster.NewEposPrintFromXML(body)
+	if err != nil {
+		fmt.Println("Failed to parse ePOS document:", err)
+		writeEposResponse(w, raster.NewEposResponse(false, raster.EPOS_CODE_SCHEMA_ERROR, 0), printJobID)
+		return
+	}
+	if eposPrint.PrintJobID != "" {
+		printJobID = eposPrint.PrintJobID
+	}
+
 	// Check the type of ePOS command, and handle accordingly
-	// Check if it's an image print request
-	if strings.Contains(string(body), EPOS_IMAGE) {
-		img, err := raster.NewRasterImageFromXML(body)
-		if err != nil {
-			http.Error(w, "Failed to parse image data", http.StatusInternalServerError)
-			fmt.Println("Failed to parse image data:", err)
-			return
-		}
-		err = printer.PrintRasterImage(img)
+	switch {
+	case eposPrint.IsRasterOnly():
+		// 图片（Odoo小票）走光栅打印流程，使用打印机配置的转换器，多张图片之间按<cut>分页
+		job := eprinter.NewRasterJob(eposPrint.ToRasterImage(eprinter.PaperWidth(printer)))
+		job.Source = remoteIP(r)
+		job.Copies = copies
+		err = printEposJob(printer, job, eposPrint.Timeout)
 		if err != nil {
-			http.Error(w, "Failed to print image", http.StatusInternalServerError)
 			fmt.Println("Failed to print image:", err)
-			return
+		} else {
+			fmt.Println("Image print success.", printer)
 		}
-		fmt.Println("Image print success.", printer)
-	} else if strings.Contains(string(body), EPOS_PULSE) {
+	case eposPrint.IsPulseOnly():
 		// Check if it's a request to open the cash drawer
-		err := printer.OpenCashBox()
+		job := eprinter.NewPulseJob()
+		job.Source = remoteIP(r)
+		err = printEposJob(printer, job, eposPrint.Timeout)
 		if err != nil {
-			http.Error(w, "Failed to open cash drawer", http.StatusInternalServerError)
 			fmt.Println("Failed to open cash drawer:", err)
-			return
+		} else {
+			fmt.Println("Cash drawer opened successfully.", printer)
+		}
+	case len(eposPrint.Commands) > 0:
+		// 其他ePOS-Print文档按顺序转换为ESC/POS指令发送
+		job := eprinter.NewEscPosJob(eposPrint.ToEscPosCommand(eprinter.PaperWidth(printer)))
+		job.Source = remoteIP(r)
+		job.Copies = copies
+		err = printEposJob(printer, job, eposPrint.Timeout)
+		if err != nil {
+			fmt.Println("Failed to print ePOS document:", err)
+		} else {
+			fmt.Println("ePOS document print success.", printer)
 		}
-		// Cash drawer opened successfully
-		fmt.Println("Cash drawer opened successfully.", printer)
-	} else if strings.Contains(string(body), EPOS_TEST) {
+	case strings.Contains(string(body), EPOS_TEST):
 		// Handle test page print request
-		err := PrintTestPage(printer)
+		err = PrintTestPage(printer)
+	case eposPrint.XMLName.Local == "epos-print":
+		// 空的ePOS-Print文档用于查询打印机状态
+		status, err := eprinter.Status(printer)
+		if errors.Is(err, eprinter.ErrStatusUnsupported) {
+			// 不支持状态查询的打印机（如Star打印机）按正常处理，避免POS一直显示打印机错误
+			err = nil
+		}
 		if err != nil {
-			http.Error(w, "Failed to print test page", http.StatusInternalServerError)
-			fmt.Println("Failed to print test page:", err)
+			writeEposResponse(w, eposResponseFromError(err), printJobID)
 			return
 		}
-	} else {
+		response := eposResponseFromError(status.Err())
+		response.Status |= status.ASB()
+		writeEposResponse(w, response, printJobID)
+		return
+	default:
 		fmt.Println("Unsupported ePOS command", string(body))
-		http.Error(w, "Unsupported command, only support <pulse> and <image>", http.StatusInternalServerError)
+		writeEposResponse(w, raster.NewEposResponse(false, raster.EPOS_CODE_SCHEMA_ERROR, 0), printJobID)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/xml")
+	writeEposResponse(w, eposResponseWithStatus(printer, err), printJobID)
+}
+
+// printEposJob 打印ePOS请求的任务，timeout为SOAP头中的timeout（毫秒），
+// 超时后保存在磁盘队列中的任务转为后台打印，其他还没有开始的任务被取消；没有指定时使用PrintJob的等待时间
+func printEposJob(printer eprinter.EPrinter, job *eprinter.Job, timeout int) error {
+	if timeout <= 0 {
+		return eprinter.PrintJob(printer, job)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+	return eprinter.PrintJobContext(ctx, printer, job)
+}
+
+// eposResponseWithStatus 打印失败时查询打印机实时状态，返回更准确的错误码和状态位
+func eposResponseWithStatus(printer eprinter.EPrinter, err error) *raster.EposResponse {
+	response := eposResponseFromError(err)
+	if err == nil || errors.Is(err, eprinter.ErrJobDeferred) {
+		return response
+	}
+	status, statusErr := eprinter.Status(printer)
+	if statusErr != nil {
+		return response
+	}
+	if printerErr := status.Err(); printerErr != nil {
+		response = eposResponseFromError(printerErr)
+	}
+	response.Status |= status.ASB()
+	return response
+}
+
+// eposResponseFromError 将打印机错误转换为ePOS响应的错误码和状态位
+func eposResponseFromError(err error) *raster.EposResponse {
+	switch {
+	case err == nil:
+		return raster.NewEposResponse(true, "", raster.ASB_PRINT_SUCCESS)
+	case errors.Is(err, eprinter.ErrJobDeferred):
+		// 任务已保存在打印队列中，打印机恢复后自动打印，返回成功避免POS重复打印
+		return raster.NewEposResponse(true, "", raster.ASB_OFF_LINE|raster.ASB_WAIT_ON_LINE)
+	case errors.Is(err, eprinter.ErrCoverOpen):
+		return raster.NewEposResponse(false, raster.EPOS_CODE_COVER_OPEN, raster.ASB_COVER_OPEN|raster.ASB_OFF_LINE)
+	case errors.Is(err, eprinter.ErrPaperEnd):
+		return raster.NewEposResponse(false, raster.EPOS_CODE_REC_EMPTY, raster.ASB_RECEIPT_END|raster.ASB_OFF_LINE)
+	case errors.Is(err, eprinter.ErrAutoCutter):
+		return raster.NewEposResponse(false, raster.EPOS_CODE_CUTTER, raster.ASB_AUTOCUTTER_ERR|raster.ASB_OFF_LINE)
+	case errors.Is(err, eprinter.ErrPrinterOffline):
+		return raster.NewEposResponse(false, raster.EPOS_CODE_BADPORT, raster.ASB_NO_RESPONSE|raster.ASB_OFF_LINE)
+	case eprinter.IsTimeout(err):
+		return raster.NewEposResponse(false, raster.EPOS_CODE_TIMEOUT, raster.ASB_NO_RESPONSE)
+	default:
+		return raster.NewEposResponse(false, raster.EPOS_CODE_PRINT_SYSTEM, 0)
+	}
+}
+
+// writeEposResponse 输出SOAP格式的ePOS响应，与Epson打印机一致，错误也返回200状态码
+func writeEposResponse(w http.ResponseWriter, response *raster.EposResponse, printJobID string) {
+	response.PrintJobID = printJobID
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(EPOS_RESPONSE))
+	w.Write(response.ToXML())
 }
 
 func StartHttpServer() {
@@ -128,9 +224,31 @@ func StartHttpServer() {
 	http.HandleFunc("/eprint/png", ePrintPNGhandler)        // 处理 PNG 打印请求
 	http.HandleFunc("/eprint/raw", ePrintRAWhandler)        // 处理RAW指令打印请求
 	http.HandleFunc("/eprint/local", ePrintLocalPNGhandler) // 处理本地PNG文件打印请求
+	http.HandleFunc("/eprint/job", ePrintJobHandler)        // 查询打印任务状态
+	http.HandleFunc("/eprint/status", ePrintStatusHandler)  // 查询打印机实时状态
 	http.HandleFunc("/tspl/label01", tsplhandler01)         // 处理TSPL标签打印请求
 	http.HandleFunc("/tspl/label02", tsplhandler02)         // 处理TSPL标签打印请求
 	http.HandleFunc("/", ePOShandler)                       // 处理根路径的请求
+	http.Handle("/hw_proxy/", newHwProxy().NewMux())        // Odoo IoT Box的hw_proxy接口
+
+	// 控制台页面使用的接口
+	http.HandleFunc("GET /dashboard/printers", dashboardPrintersHandler)
+	http.HandleFunc("POST /dashboard/{action}", adminAuth(dashboardActionHandler)) // 打印和打开钱箱需要管理令牌
+
+	// 打印历史，包含小票内容和请求来源，都需要管理令牌
+	http.HandleFunc("GET /history/jobs", adminAuth(historyListHandler))
+	http.HandleFunc("GET /history/jobs/{id}", adminAuth(historyGetHandler))
+	http.HandleFunc("GET /history/jobs/{id}/preview", adminAuth(historyPreviewHandler))
+	http.HandleFunc("POST /history/jobs/{id}/reprint", adminAuth(historyReprintHandler))
+
+	// 管理接口，需要EPOS_ADMIN_TOKEN或 -token-file 设置的令牌
+	http.HandleFunc("GET /admin/printers", adminAuth(adminListPrinters))            // 打印机列表
+	http.HandleFunc("GET /admin/printers/{name}", adminAuth(adminGetPrinter))       // 打印机配置
+	http.HandleFunc("POST /admin/printers/{name}", adminAuth(adminPutPrinter))      // 添加打印机
+	http.HandleFunc("PUT /admin/printers/{name}", adminAuth(adminPutPrinter))       // 添加或修改打印机
+	http.HandleFunc("DELETE /admin/printers/{name}", adminAuth(adminDeletePrinter)) // 删除打印机
+	http.HandleFunc("GET /admin/discover", adminAuth(adminDiscoverPrinters))        // 扫描局域网中的打印机
+	http.HandleFunc("PUT /admin/printers/{name}/logo", adminAuth(adminPutLogo))     // 保存NV图像
 
 	cert, err := tls.X509KeyPair(ServerCert, ServerKey)
 	if err != nil {
@@ -158,7 +276,7 @@ func StartHttpServer() {
 	fmt.Println("Version:", Version)
 	fmt.Println("Available printers:")
 	// 打印所有可用的打印机
-	for name, printer := range Printers {
+	for name, printer := range GetPrinters() {
 		fmt.Println("Printer:", name, printer)
 	}
 
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+	"github.com/xiaohao0576/odoo-epos/printer/printertest"
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// setTestPrinters 将打印机p1指向模拟打印机，测试结束后恢复原来的打印机
+func setTestPrinters(t *testing.T, s *printertest.Server) {
+	t.Helper()
+	config := s.Config()
+	spool := eprinter.NewSpoolPrinter("p1", config.NewPrinter(), 0)
+	old := GetPrinters()
+	printersMu.Lock()
+	Printers = eprinter.Printers{"p1": spool}
+	printersMu.Unlock()
+	t.Cleanup(func() {
+		spool.Close()
+		printersMu.Lock()
+		Printers = old
+		printersMu.Unlock()
+	})
+}
+
+func eposRequest(t *testing.T, path, body string) raster.EposResponse {
+	t.Helper()
+	envelope := `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+<s:Header><parameter xmlns="http://www.epson-pos.com/schemas/2011/03/epos-print"><printjobid>job-1</printjobid></parameter></s:Header>
+<s:Body><epos-print xmlns="http://www.epson-pos.com/schemas/2011/03/epos-print">` + body + `</epos-print></s:Body>
+</s:Envelope>`
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(envelope))
+	w := httptest.NewRecorder()
+	ePOShandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST %s: status %d: %s", path, w.Code, w.Body)
+	}
+	var response struct {
+		Body struct {
+			Response raster.EposResponse `xml:"response"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("POST %s: invalid response %q: %v", path, w.Body, err)
+	}
+	return response.Body.Response
+}
+
+func TestEposPrintText(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	setTestPrinters(t, s)
+
+	response := eposRequest(t, "/p1/cgi-bin/epos/service.cgi", `<text>hello&#10;</text><cut type="feed"/>`)
+	if !response.Success || response.PrintJobID != "job-1" {
+		t.Fatalf("response %+v, want success for job-1", response)
+	}
+	jobs, err := s.WaitJobs(1, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(jobs[0].Data, []byte("hello\n")) {
+		t.Errorf("printer received %q, want it to contain %q", jobs[0].Data, "hello\n")
+	}
+}
+
+func TestEposStatus(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	setTestPrinters(t, s)
+
+	response := eposRequest(t, "/p1/cgi-bin/epos/service.cgi", "")
+	if !response.Success {
+		t.Fatalf("online printer: response %+v, want success", response)
+	}
+
+	s.SetStatus(eprinter.PrinterStatus{Online: true, PaperEnd: true})
+	response = eposRequest(t, "/p1/cgi-bin/epos/service.cgi", "")
+	if response.Success || response.Code != raster.EPOS_CODE_REC_EMPTY || response.Status&raster.ASB_RECEIPT_END == 0 {
+		t.Errorf("paper end: response %+v, want %s", response, raster.EPOS_CODE_REC_EMPTY)
+	}
+	if len(s.Jobs()) != 0 {
+		t.Errorf("status request printed %d jobs", len(s.Jobs()))
+	}
+}
+
+func TestEposPrinterOffline(t *testing.T) {
+	s := printertest.NewServer()
+	setTestPrinters(t, s)
+	s.Close()
+
+	response := eposRequest(t, "/p1/cgi-bin/epos/service.cgi", `<text>hello&#10;</text>`)
+	if response.Success || response.Code != raster.EPOS_CODE_BADPORT {
+		t.Errorf("response %+v, want %s", response, raster.EPOS_CODE_BADPORT)
+	}
+}
+
+func TestEposPrinterNotFound(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	setTestPrinters(t, s)
+
+	response := eposRequest(t, "/p2/cgi-bin/epos/service.cgi", `<text>hello&#10;</text>`)
+	if response.Success || response.Code != raster.EPOS_CODE_DEVICE_NOT_FOUND {
+		t.Errorf("response %+v, want %s", response, raster.EPOS_CODE_DEVICE_NOT_FOUND)
+	}
+}
+
+func TestEposInvalidImage(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	setTestPrinters(t, s)
+
+	tests := []struct {
+		name  string
+		image string
+	}{
+		{"zero width", `<image width="0" height="8">AAAA</image>`},
+		{"negative width", `<image width="-8" height="1">AA==</image>`},
+		{"too large", `<image width="200000" height="200000">AA==</image>`},
+		{"too large gray16", `<image width="4096" height="20000" mode="gray16">AA==</image>`},
+		{"data too short", `<image width="16" height="2">AAA=</image>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := eposRequest(t, "/p1/cgi-bin/epos/service.cgi", tt.image)
+			if response.Success || response.Code != raster.EPOS_CODE_SCHEMA_ERROR {
+				t.Errorf("response %+v, want %s", response, raster.EPOS_CODE_SCHEMA_ERROR)
+			}
+		})
+	}
+	if len(s.Jobs()) != 0 {
+		t.Errorf("invalid images printed %d jobs", len(s.Jobs()))
+	}
+}
+
+func TestEposHLineClamped(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	setTestPrinters(t, s)
+
+	// 超出纸张的横线按纸张宽度打印，不按请求中的坐标分配图像
+	response := eposRequest(t, "/p1/cgi-bin/epos/service.cgi", `<hline x1="0" x2="1000000000" style="thin"/><hline x1="-8" x2="100"/>`)
+	if !response.Success {
+		t.Fatalf("response %+v, want success", response)
+	}
+	jobs, err := s.WaitJobs(1, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs[0].Image == nil || jobs[0].Image.Width > 576 || len(jobs[0].Data) > 1024 {
+		t.Errorf("printer received %d bytes rendered as %v, want one line at most 576 dots wide", len(jobs[0].Data), jobs[0].Image)
+	}
+}
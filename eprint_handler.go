@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -13,8 +14,10 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
 	"github.com/xiaohao0576/odoo-epos/raster"
 )
 
@@ -30,11 +33,13 @@ func ePrintPNGhandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	var printerName, pngUrl string
+	var printerName, pngUrl, async, copiesParam string
 	switch r.Method {
 	case http.MethodGet:
 		printerName = r.URL.Query().Get("x_printer")
 		pngUrl = r.URL.Query().Get("x_url")
+		async = r.URL.Query().Get("x_async")
+		copiesParam = r.URL.Query().Get("x_copies")
 	case http.MethodPost:
 		var data map[string]string
 		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
@@ -43,6 +48,8 @@ func ePrintPNGhandler(w http.ResponseWriter, r *http.Request) {
 		}
 		printerName = data["x_printer"]
 		pngUrl = data["x_url"]
+		async = data["x_async"]
+		copiesParam = data["x_copies"]
 	default:
 		http.Error(w, `{"success":false,"msg":"Method not allowed"}`, http.StatusMethodNotAllowed)
 		return
@@ -53,7 +60,13 @@ func ePrintPNGhandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	printer, ok := Printers[printerName]
+	copies, err := parseCopies(copiesParam)
+	if err != nil {
+		http.Error(w, `{"success":false,"msg":"Invalid copies parameter"}`, http.StatusBadRequest)
+		return
+	}
+
+	printer, ok := GetPrinters()[printerName]
 	if !ok {
 		http.Error(w, `{"success":false,"msg":"Printer not found"}`, http.StatusBadRequest)
 		return
@@ -69,12 +82,14 @@ func ePrintPNGhandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"success":false,"msg":"Failed to create raster image from PNG"}`, http.StatusInternalServerError)
 		return
 	}
-	if err := printer.PrintRasterImage(img); err != nil {
-		http.Error(w, `{"success":false,"msg":"Failed to print raster image: `+err.Error()+`"}`, http.StatusInternalServerError)
+	job := eprinter.NewRasterJob(img)
+	job.Source = remoteIP(r)
+	job.Copies = copies
+	if isTrue(async) && submitAsync(w, printer, job) {
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"success":true,"msg":"Image printed successfully"}`))
+	err = eprinter.PrintJob(printer, job)
+	writePrintResult(w, printer, err, "Image printed successfully", "Failed to print raster image")
 }
 
 func ePrintRAWhandler(w http.ResponseWriter, r *http.Request) {
@@ -89,11 +104,13 @@ func ePrintRAWhandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	var printerName, rawHex string
+	var printerName, rawHex, async, copiesParam string
 	switch r.Method {
 	case http.MethodGet:
 		printerName = r.URL.Query().Get("x_printer")
 		rawHex = r.URL.Query().Get("x_hex")
+		async = r.URL.Query().Get("x_async")
+		copiesParam = r.URL.Query().Get("x_copies")
 	case http.MethodPost:
 		var data map[string]string
 		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
@@ -102,6 +119,8 @@ func ePrintRAWhandler(w http.ResponseWriter, r *http.Request) {
 		}
 		printerName = data["x_printer"]
 		rawHex = data["x_hex"]
+		async = data["x_async"]
+		copiesParam = data["x_copies"]
 	default:
 		http.Error(w, `{"success":false,"msg":"Method not allowed"}`, http.StatusMethodNotAllowed)
 		return
@@ -112,7 +131,13 @@ func ePrintRAWhandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	printer, ok := Printers[printerName]
+	copies, err := parseCopies(copiesParam)
+	if err != nil {
+		http.Error(w, `{"success":false,"msg":"Invalid copies parameter"}`, http.StatusBadRequest)
+		return
+	}
+
+	printer, ok := GetPrinters()[printerName]
 	if !ok {
 		http.Error(w, `{"success":false,"msg":"Printer not found"}`, http.StatusBadRequest)
 		return
@@ -123,12 +148,57 @@ func ePrintRAWhandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"success":false,"msg":"Invalid hex data: `+err.Error()+`"}`, http.StatusBadRequest)
 		return
 	}
-	if err := printer.PrintRaw(rawBytes); err != nil {
-		http.Error(w, `{"success":false,"msg":"Failed to print raw commands: `+err.Error()+`"}`, http.StatusInternalServerError)
+	job := eprinter.NewRawJob(rawBytes)
+	job.Source = remoteIP(r)
+	job.Copies = copies
+	if isTrue(async) && submitAsync(w, printer, job) {
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"success":true,"msg":"Raw commands printed successfully"}`))
+	err = eprinter.PrintJob(printer, job)
+	writePrintResult(w, printer, err, "Raw commands printed successfully", "Failed to print raw commands")
+}
+
+// writePrintResult 输出打印结果，打印机离线时任务已保存在打印队列中等待重试，
+// 镜像打印机附带每台成员的打印结果
+func writePrintResult(w http.ResponseWriter, printer eprinter.EPrinter, err error, successMsg, failMsg string) {
+	result := map[string]any{"success": true, "msg": successMsg}
+	status := http.StatusOK
+	switch {
+	case err == nil:
+	case errors.Is(err, eprinter.ErrJobDeferred):
+		status = http.StatusAccepted
+		result["msg"] = err.Error()
+	default:
+		status = http.StatusInternalServerError
+		result["success"] = false
+		result["msg"] = failMsg + ": " + err.Error()
+	}
+	if members := eprinter.MirrorResults(printer, err); members != nil {
+		result["members"] = members
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// isTrue 判断请求参数是否为真
+func isTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// parseCopies 解析打印份数参数，未设置时返回0，使用打印机配置的份数
+func parseCopies(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	copies, err := strconv.Atoi(value)
+	if err != nil || copies < 1 || copies > eprinter.MaxCopies {
+		return 0, fmt.Errorf("invalid copies %q, must be 1~%d", value, eprinter.MaxCopies)
+	}
+	return copies, nil
 }
 
 // DownloadPngImage 从指定URL下载PNG图片并解码为image.Image
@@ -256,7 +326,7 @@ func ePrintLocalPNGhandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	printer, ok := Printers[printerName]
+	printer, ok := GetPrinters()[printerName]
 	if !ok {
 		http.Error(w, `{"success":false,"msg":"Printer not found"}`, http.StatusBadRequest)
 		return
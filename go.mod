@@ -2,9 +2,8 @@ module github.com/xiaohao0576/odoo-epos
 
 go 1.24.3
 
-require github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
-
 require (
-	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/text v0.26.0 // indirect
+	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
+	golang.org/x/sys v0.33.0
+	golang.org/x/text v0.26.0
 )
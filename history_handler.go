@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+)
+
+var JobHistory *eprinter.History
+
+// historyListHandler GET /history/jobs 查询打印历史，按创建时间从新到旧排序
+// 参数：x_printer 打印机，x_source 来源IP，x_q 搜索ID、打印机、来源和错误信息，
+// x_status success或failed，x_from和x_to 时间范围（2006-01-02或RFC3339），x_limit 最多返回的记录数（默认100）
+func historyListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if JobHistory == nil {
+		http.Error(w, `{"success":false,"msg":"Job history disabled"}`, http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	filter := eprinter.HistoryFilter{
+		Printer: query.Get("x_printer"),
+		Source:  query.Get("x_source"),
+		Query:   query.Get("x_q"),
+		From:    parseHistoryTime(query.Get("x_from"), false),
+		To:      parseHistoryTime(query.Get("x_to"), true),
+		Limit:   100,
+	}
+	switch query.Get("x_status") {
+	case "success":
+		filter.Success = new(bool)
+		*filter.Success = true
+	case "failed":
+		filter.Success = new(bool)
+	}
+	if limit, err := strconv.Atoi(query.Get("x_limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "jobs": JobHistory.List(filter)})
+}
+
+// remoteIP 返回请求来源的IP地址，记录在打印历史中
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parseHistoryTime 解析查询的时间，只有日期时end为true表示当天结束
+func parseHistoryTime(value string, end bool) time.Time {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	if end {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t
+}
+
+// historyGetHandler GET /history/jobs/{id} 返回一条打印记录
+func historyGetHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if JobHistory == nil {
+		http.Error(w, `{"success":false,"msg":"Job history disabled"}`, http.StatusNotFound)
+		return
+	}
+	record, err := JobHistory.Get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"success":false,"msg":"Job not found"}`, http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "job": record})
+}
+
+// historyPreviewHandler GET /history/jobs/{id}/preview 返回打印图像的PNG预览
+func historyPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if JobHistory == nil {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := JobHistory.PreviewFile(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, file)
+}
+
+// historyReprintHandler POST /history/jobs/{id}/reprint?x_printer=p2 重新打印，不指定x_printer时使用原来的打印机
+func historyReprintHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if JobHistory == nil {
+		http.Error(w, `{"success":false,"msg":"Job history disabled"}`, http.StatusNotFound)
+		return
+	}
+	id := r.PathValue("id")
+	record, err := JobHistory.Get(id)
+	if err != nil {
+		http.Error(w, `{"success":false,"msg":"Job not found"}`, http.StatusNotFound)
+		return
+	}
+	printerName := r.URL.Query().Get("x_printer")
+	if printerName == "" {
+		printerName = record.Printer
+	}
+	printer, ok := GetPrinters()[printerName]
+	if !ok {
+		http.Error(w, `{"success":false,"msg":"Printer not found"}`, http.StatusBadRequest)
+		return
+	}
+	job, err := JobHistory.Job(id)
+	if errors.Is(err, eprinter.ErrHistoryNotReprintable) {
+		http.Error(w, `{"success":false,"msg":"Cash drawer jobs can not be reprinted"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"success":false,"msg":"Job data not found"}`, http.StatusNotFound)
+		return
+	}
+	job.Source = remoteIP(r)
+	err = eprinter.PrintJob(printer, job)
+	writePrintResult(w, printer, err, "Job reprinted successfully", "Failed to reprint job")
+}
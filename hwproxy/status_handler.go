@@ -3,11 +3,14 @@ package hwproxy
 import (
 	"encoding/json"
 	"net/http"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
 )
 
 // StatusResponse 定义状态响应的结构
 type StatusResponse struct {
-	Scale map[string]string `json:"scale"`
+	Scale   map[string]string `json:"scale"`
+	Printer map[string]string `json:"printer"`
 }
 
 // StatusHandler 处理 /hw_proxy/status_json RPC POST 请求，返回硬件状态JSON数据
@@ -26,7 +29,8 @@ func (h *HwProxy) StatusHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 获取电子秤状态
 	response := StatusResponse{
-		Scale: make(map[string]string),
+		Scale:   make(map[string]string),
+		Printer: make(map[string]string),
 	}
 
 	if h.Scale != nil {
@@ -40,6 +44,29 @@ func (h *HwProxy) StatusHandler(w http.ResponseWriter, r *http.Request) {
 		response.Scale["message"] = "Scale driver not initialized"
 	}
 
+	// 获取打印机状态
+	var printer eprinter.EPrinter
+	if h.Printer != nil {
+		printer = h.Printer()
+	}
+	if printer != nil {
+		status, err := eprinter.Status(printer)
+		switch {
+		case err != nil:
+			response.Printer["status"] = "disconnected"
+			response.Printer["message"] = err.Error()
+		case status.Err() != nil:
+			response.Printer["status"] = "error"
+			response.Printer["message"] = status.String()
+		default:
+			response.Printer["status"] = "connected"
+			response.Printer["message"] = status.String()
+		}
+	} else {
+		response.Printer["status"] = "disconnected"
+		response.Printer["message"] = "Printer not configured"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
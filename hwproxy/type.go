@@ -2,9 +2,11 @@ package hwproxy
 
 import (
 	hwdriver "github.com/xiaohao0576/odoo-epos/hwdriver"
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
 )
 
 // HwProxy 用于实现 Odoo IoT Box 的 hw_proxy 相关功能
 type HwProxy struct {
-	Scale *hwdriver.SerialScaleDriver
+	Scale   *hwdriver.SerialScaleDriver
+	Printer func() eprinter.EPrinter // 返回默认打印机，用于状态查询，重新加载配置后返回新的打印机
 }
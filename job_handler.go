@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+)
+
+// ePrintJobHandler 查询打印任务状态
+// GET /eprint/job?x_job=ID 立即返回任务状态
+// GET /eprint/job?x_job=ID&x_wait=10 最多等待10秒直到任务完成
+// GET /eprint/job?x_printer=p1 返回打印机队列中的所有任务
+func ePrintJobHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Private-Network", "true")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"success":false,"msg":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	jobID := r.URL.Query().Get("x_job")
+	if jobID == "" {
+		printerName := r.URL.Query().Get("x_printer")
+		spool, ok := GetPrinters()[printerName].(*eprinter.SpoolPrinter)
+		if !ok {
+			http.Error(w, `{"success":false,"msg":"Printer not found"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "jobs": spool.Jobs()})
+		return
+	}
+
+	spool, job, ok := eprinter.FindJob(GetPrinters(), jobID)
+	if !ok {
+		http.Error(w, `{"success":false,"msg":"Job not found"}`, http.StatusNotFound)
+		return
+	}
+	if wait, err := strconv.Atoi(r.URL.Query().Get("x_wait")); err == nil && wait > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(min(wait, 60))*time.Second)
+		defer cancel()
+		job, _ = spool.Wait(ctx, jobID)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": job.State != eprinter.JobFailed, "job": job})
+}
+
+// submitAsync 异步提交打印任务，返回任务ID供调用方轮询，打印机不支持队列时返回false
+func submitAsync(w http.ResponseWriter, printer eprinter.EPrinter, job *eprinter.Job) bool {
+	spool, ok := printer.(*eprinter.SpoolPrinter)
+	if !ok {
+		return false
+	}
+	id, err := spool.Submit(job)
+	if err != nil {
+		http.Error(w, `{"success":false,"msg":"Failed to submit job: `+err.Error()+`"}`, http.StatusServiceUnavailable)
+		return true
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "msg": "Job submitted", "job_id": id})
+	return true
+}
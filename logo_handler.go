@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// adminPutLogo PUT /admin/printers/{name}/logo?key1=32&key2=32 将请求中的PNG图片保存到打印机的NV内存，
+// 之后ePOS文档中的<logo key1="32" key2="32"/>直接打印保存的图片，不需要每次传送图像数据
+func adminPutLogo(w http.ResponseWriter, r *http.Request) {
+	printer, ok := GetPrinters()[r.PathValue("name")]
+	if !ok {
+		writeAdminResult(w, http.StatusNotFound, "Printer not found", nil)
+		return
+	}
+	kc1, err1 := logoKey(r.URL.Query().Get("key1"))
+	kc2, err2 := logoKey(r.URL.Query().Get("key2"))
+	if err := errors.Join(err1, err2); err != nil {
+		writeAdminResult(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	pngImg, err := png.Decode(r.Body)
+	if err != nil {
+		writeAdminResult(w, http.StatusBadRequest, "Invalid PNG image: "+err.Error(), nil)
+		return
+	}
+	img := raster.NewRasterImageFromImage(pngImg)
+	if img == nil {
+		writeAdminResult(w, http.StatusBadRequest, "Failed to create raster image from PNG", nil)
+		return
+	}
+	if width := eprinter.PaperWidth(printer); width > 0 && img.Width > width {
+		writeAdminResult(w, http.StatusBadRequest, "Logo is wider than the paper", nil)
+		return
+	}
+
+	job := eprinter.NewRawJob(img.ToEscPosNVGraphicsCommand(kc1, kc2))
+	job.Source = remoteIP(r)
+	if err := eprinter.PrintJob(printer, job); err != nil && !errors.Is(err, eprinter.ErrJobDeferred) {
+		writeAdminResult(w, http.StatusInternalServerError, "Failed to store logo: "+err.Error(), nil)
+		return
+	}
+	writeAdminResult(w, http.StatusOK, "Logo stored", nil)
+}
+
+// logoKey 解析NV图像的key，范围32~126，默认32
+func logoKey(value string) (byte, error) {
+	if value == "" {
+		return 32, nil
+	}
+	key, err := strconv.Atoi(value)
+	if err != nil || key < 32 || key > 126 {
+		return 0, fmt.Errorf("invalid logo key %q, must be 32~126", value)
+	}
+	return byte(key), nil
+}
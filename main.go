@@ -3,29 +3,85 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/xiaohao0576/odoo-epos/emulator"
 	eprinter "github.com/xiaohao0576/odoo-epos/printer"
 )
 
 var (
-	Version    = "1.4.0"
-	Port       *string
-	ConfigFile *string
-	Printers   eprinter.Printers
+	Version     = "1.4.0"
+	Port        *string
+	ConfigFile  *string
+	AdminToken  *string
+	TokenFile   *string
+	HistoryDir  *string
+	HistoryDays *int
+	Emulator    *string
+	Printers    eprinter.Printers
 )
 
 func init() {
 	ConfigFile = flag.String("c", "config.json", "Path to the configuration file")
 	Port = flag.String("p", "443", "Port to run the server on")
+	AdminToken = flag.String("t", "", "Token for the /admin API (visible in the process list, prefer $EPOS_ADMIN_TOKEN or -token-file)")
+	TokenFile = flag.String("token-file", "", "File containing the token for the /admin API")
+	HistoryDir = flag.String("history", "history", "Directory to keep the print job history, empty to disable it")
+	HistoryDays = flag.Int("history-days", 7, "Days to keep the print job history, 0 to keep forever")
+	Emulator = flag.String("emulator", "", "Address of a virtual ESC/POS network printer to run (e.g. :9100), received jobs are saved as png in ./emulator")
+}
+
+func main() {
+	// 在main中解析参数，测试时go test的参数不会传给init
 	flag.Parse()
-	if fileNotExists(*ConfigFile) {
+	if flag.Arg(0) == "" && fileNotExists(*ConfigFile) {
 		fmt.Println("config file not exist, downloading...")
 		const configFileUrl = "https://d2ctjms1d0nxe6.cloudfront.net/cert/config.json"
 		DownloadFile(configFileUrl, *ConfigFile)
 	}
-}
-
-func main() {
+	loadAdminToken()
+	if flag.Arg(0) == "discover" {
+		discoverCommand() // 扫描局域网中的打印机并输出配置
+		return
+	}
+	if *HistoryDir != "" {
+		history, err := eprinter.OpenHistory(*HistoryDir, time.Duration(*HistoryDays)*24*time.Hour)
+		if err != nil {
+			fmt.Println("Job history disabled:", err)
+		} else {
+			JobHistory = history
+			eprinter.SetHistory(history)
+		}
+	}
+	if *Emulator != "" {
+		server, err := emulator.Listen(*Emulator, 576, emulator.SaveTo("emulator"))
+		if err != nil {
+			fmt.Println("Failed to start printer emulator:", err)
+		} else {
+			fmt.Println("Printer emulator listening on", server.Addr())
+		}
+	}
 	Printers, _ = eprinter.LoadPrinters(*ConfigFile)
+	go WatchConfig(*ConfigFile) // 配置文件修改后自动重新加载打印机
 	StartHttpServer()
 }
+
+// loadAdminToken 按 -t、-token-file、环境变量EPOS_ADMIN_TOKEN的顺序读取管理令牌，都没有时管理接口不可用。
+// 命令行参数在ps中可以看到，应使用文件或环境变量
+func loadAdminToken() {
+	switch {
+	case *AdminToken != "":
+		fmt.Println("Warning: -t shows the admin token in the process list, use EPOS_ADMIN_TOKEN or -token-file instead")
+	case *TokenFile != "":
+		data, err := os.ReadFile(*TokenFile)
+		if err != nil {
+			fmt.Println("Admin API disabled, failed to read token file:", err)
+			return
+		}
+		*AdminToken = strings.TrimSpace(string(data))
+	default:
+		*AdminToken = os.Getenv("EPOS_ADMIN_TOKEN")
+	}
+}
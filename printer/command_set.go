@@ -0,0 +1,116 @@
+package printer
+
+import "github.com/xiaohao0576/odoo-epos/raster"
+
+// CommandSet 打印机指令集，生成初始化、光栅图像、切纸和钱箱指令。
+// 配置中的cut_command和cash_drawer_command优先于指令集的默认指令，profile加在初始化指令之后和任务结束时
+type CommandSet interface {
+	Init() []byte                                          // 任务开始时初始化打印机
+	Trailer() []byte                                       // 任务结束时发送的指令
+	Raster(page *raster.RasterImage, twoColor bool) []byte // 打印一页光栅图像
+	Cut() []byte                                           // 走纸并切纸
+	CashDrawer() []byte                                    // 打开钱箱
+	RealTimeStatus() bool                                  // 是否支持DLE EOT和GS a状态查询
+}
+
+// CommandSets 可以在配置的command_set中使用的指令集，未配置时使用escpos
+var CommandSets = map[string]func(c *ConfigPrinter) CommandSet{
+	"escpos": func(c *ConfigPrinter) CommandSet {
+		return escposCommands{rasterMode: c.RasterMode, compress: c.Compress, profile: c.Profile}
+	},
+	"star": func(c *ConfigPrinter) CommandSet { return starCommands{compress: c.Compress, profile: c.Profile} },
+}
+
+// RasterModes ESC/POS打印机可以在配置的raster_mode中选择的光栅图像编码方式，未配置时使用raster
+var RasterModes = map[string]func(page *raster.RasterImage) []byte{
+	"raster":   func(page *raster.RasterImage) []byte { return page.ToEscPosRasterCommand(1024) }, // GS v 0
+	"graphics": (*raster.RasterImage).ToEscPosGraphicsCommand,                                     // GS ( L / GS 8 L
+	"column":   (*raster.RasterImage).ToEscPosBitImageCommand,                                     // ESC *，旧打印机和针式打印机
+}
+
+// escposCommands Epson ESC/POS 指令集
+type escposCommands struct {
+	rasterMode string // 光栅图像的编码方式，见RasterModes
+	compress   bool   // 连续的空白行用ESC J走纸代替，去掉右侧空白
+	profile    *PrinterProfile
+}
+
+func (c escposCommands) Init() []byte {
+	return append([]byte{0x1B, 0x40}, c.profile.escposInit()...) // ESC @
+}
+
+func (c escposCommands) Trailer() []byte {
+	return c.profile.escposTrailer()
+}
+
+// Raster 按配置的编码方式输出光栅图像，双色打印机按图像颜色使用GS ( L打印
+func (c escposCommands) Raster(page *raster.RasterImage, twoColor bool) []byte {
+	if twoColor && page.IsColor() {
+		return page.ToEscPosColorRasterCommand()
+	}
+	encode, ok := RasterModes[c.rasterMode]
+	if !ok {
+		encode = RasterModes["raster"]
+	}
+	var data []byte
+	if !c.compress {
+		data = encode(page)
+	} else {
+		align := 1
+		if c.rasterMode == "column" {
+			align = 24 // ESC *每条打印24行
+		}
+		data = page.ToCompactCommand(encode, align, raster.EscPosFeed)
+	}
+	if c.rasterMode == "column" {
+		// ESC *打印后用ESC 2恢复默认行距，配置了行间距时恢复为配置的行间距
+		data = append(data, c.profile.lineSpacingCommand()...)
+	}
+	return data
+}
+
+func (escposCommands) Cut() []byte {
+	return []byte{0x1D, 0x56, 0x01} // GS V 1 半切纸
+}
+
+func (escposCommands) CashDrawer() []byte {
+	return []byte{0x1B, 0x70, 0x00, 0x19, 0xFA} // ESC p 0 脉冲50ms/500ms
+}
+
+func (escposCommands) RealTimeStatus() bool {
+	return true
+}
+
+// starCommands Star Line Mode 指令集，适用于TSP100、TSP650等Star打印机，
+// Star打印机不响应DLE EOT和GS a，不支持状态查询，也不支持双色打印
+type starCommands struct {
+	compress bool // 每行去掉右侧空白，连续的空白行用ESC * r Y走纸代替
+	profile  *PrinterProfile
+}
+
+func (c starCommands) Init() []byte {
+	return append([]byte{0x1B, 0x40}, c.profile.initCommand()...) // ESC @
+}
+
+func (c starCommands) Trailer() []byte {
+	return c.profile.trailerCommand()
+}
+
+func (c starCommands) Raster(page *raster.RasterImage, twoColor bool) []byte {
+	if c.compress {
+		return page.ToStarCompactRasterCommand()
+	}
+	return page.ToStarRasterCommand()
+}
+
+func (starCommands) Cut() []byte {
+	return []byte{0x1B, 0x64, 0x03} // ESC d 3 走纸到切刀位置并半切纸
+}
+
+func (starCommands) CashDrawer() []byte {
+	return []byte{0x07} // BEL 打开钱箱1
+}
+
+func (starCommands) RealTimeStatus() bool {
+	return false
+}
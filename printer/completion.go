@@ -0,0 +1,85 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// 串口和USB打印机确认任务完成的方式，配置在completion中
+const (
+	CompletionSleep    = "sleep"    // 每页固定等待1秒（默认）
+	CompletionReceived = "received" // 发送DLE EOT 1，收到响应说明打印机已收到前面的所有数据
+	CompletionPrinted  = "printed"  // 发送GS ( H处理ID请求，收到响应说明打印机已处理完前面的所有数据
+)
+
+const (
+	completionTimeout = 10 * time.Second // 等待完成响应的基本时间，慢速串口按数据量增加
+	sleepPerPage      = time.Second      // sleep方式每页等待的时间
+)
+
+var processID atomic.Uint32 // GS ( H 处理ID，每次请求使用不同的ID
+
+// deadlineReadWriter 可以设置读取超时的打印机连接，如*os.File和serialPort
+type deadlineReadWriter interface {
+	io.ReadWriter
+	SetReadDeadline(t time.Time) error
+}
+
+// waitCompletion 按mode等待打印机处理完已发送的数据，mode为sleep或未配置时立即返回。
+// 数据已经全部发送，timeout内没有收到响应时返回的错误不是ErrTimeout，打印队列不会重试而重复打印
+func waitCompletion(rw deadlineReadWriter, mode string, timeout time.Duration) error {
+	var query []byte
+	var done func(resp []byte) bool
+	switch mode {
+	case CompletionReceived:
+		// DLE EOT是实时指令，但在串口和USB上排在前面的数据之后传送，响应说明前面的数据已经到达打印机
+		query = dleEOT[0]
+		done = func(resp []byte) bool {
+			return bytes.ContainsFunc(resp, func(r rune) bool { return r&0x93 == 0x12 })
+		}
+	case CompletionPrinted:
+		// GS ( H <功能48> 在处理完前面的所有数据后返回 37 22 d1 d2 d3 d4 00，d为'0'~'9'
+		id := fmt.Appendf(nil, "%04d", processID.Add(1)%10000)
+		query = append([]byte{0x1D, '(', 'H', 6, 0, 48, 48}, id...)
+		want := append(append([]byte{0x37, 0x22}, id...), 0x00)
+		done = func(resp []byte) bool {
+			return bytes.Contains(resp, want)
+		}
+	default:
+		return nil
+	}
+
+	if _, err := rw.Write(query); err != nil {
+		return fmt.Errorf("%w: %w", ErrPrinterOffline, err)
+	}
+	deadline := time.Now().Add(timeout)
+	rw.SetReadDeadline(deadline)
+	defer rw.SetReadDeadline(time.Time{})
+	var resp []byte
+	buf := make([]byte, 64)
+	for {
+		n, err := rw.Read(buf)
+		resp = append(resp, buf[:n]...)
+		switch {
+		case done(resp):
+			return nil
+		case IsTimeout(err) || time.Now().After(deadline):
+			return fmt.Errorf("no %s response from printer within %v", mode, timeout)
+		case err == io.EOF && n == 0:
+			time.Sleep(10 * time.Millisecond) // 设备没有数据时可能立即返回EOF
+		case err != nil && err != io.EOF:
+			return err
+		}
+	}
+}
+
+// transferTimeout 返回以baud波特率传送n字节所需的时间加上completionTimeout，baud为0时不计算传送时间
+func transferTimeout(n, baud int) time.Duration {
+	if baud <= 0 {
+		return completionTimeout
+	}
+	return completionTimeout + time.Duration(n)*10*time.Second/time.Duration(baud) // 每字节约10位
+}
@@ -0,0 +1,85 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+const MaxCopies = 20 // 一个任务最多打印的份数
+
+// rasterCopiesPrinter 可以一次打印多份图像的打印机，copies为0时使用打印机配置的份数
+type rasterCopiesPrinter interface {
+	PrintRasterCopies(img *raster.RasterImage, copies int) error
+}
+
+// rawCopiesPrinter 可以一次打印多份原始指令的打印机，copies为0时使用打印机配置的份数
+type rawCopiesPrinter interface {
+	PrintRawCopies(data []byte, copies int) error
+}
+
+// escposCopiesPrinter 可以打印ePOS文档转换的ESC/POS指令的打印机，指令前后加上打印机设置
+type escposCopiesPrinter interface {
+	PrintEscPosCopies(data []byte, copies int) error
+}
+
+// printRasterCopies 在打印机上打印copies份图像，打印机不支持多份时逐份打印
+func printRasterCopies(p EPrinter, img *raster.RasterImage, copies int) error {
+	if cp, ok := p.(rasterCopiesPrinter); ok {
+		return cp.PrintRasterCopies(img, copies)
+	}
+	for range max(copies, 1) {
+		if err := p.PrintRasterImage(img.Clone()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printRawCopies 在打印机上打印copies份原始指令，打印机不支持多份时把指令重复copies次发送
+func printRawCopies(p EPrinter, data []byte, copies int) error {
+	if cp, ok := p.(rawCopiesPrinter); ok {
+		return cp.PrintRawCopies(data, copies)
+	}
+	return p.PrintRaw(bytes.Repeat(data, max(copies, 1)))
+}
+
+// printEscPosCopies 在打印机上打印copies份ePOS文档转换的ESC/POS指令，打印机不支持时按原始指令打印
+func printEscPosCopies(p EPrinter, data []byte, copies int) error {
+	if cp, ok := p.(escposCopiesPrinter); ok {
+		return cp.PrintEscPosCopies(data, copies)
+	}
+	return printRawCopies(p, data, copies)
+}
+
+// escposCopies 返回打印copies份ESC/POS指令的数据，前后加上打印机设置。
+// 指令开头的 ESC @ 会清除打印机设置，Init已经包含 ESC @，所以去掉
+func escposCopies(commands CommandSet, data []byte, copies int) []byte {
+	data = bytes.TrimPrefix(data, []byte{0x1B, '@'})
+	return slices.Concat(commands.Init(), bytes.Repeat(data, max(copies, 1)), commands.Trailer())
+}
+
+// repeatCopies 把每页的打印数据按份数重复，每页只生成一次。
+// bannerWidth大于0且多于一份时，每份第一页前面加上 "COPY n/N" 标题
+func repeatCopies(pages [][]byte, copies int, commands CommandSet, bannerWidth int) [][]byte {
+	copies = max(copies, 1)
+	result := make([][]byte, 0, len(pages)*copies)
+	for n := 1; n <= copies; n++ {
+		for i, page := range pages {
+			if i == 0 && bannerWidth > 0 && copies > 1 {
+				page = append(commands.Raster(copyBanner(n, copies, bannerWidth), false), page...)
+			}
+			result = append(result, page)
+		}
+	}
+	return result
+}
+
+// copyBanner 返回第n份的标题图像，文字靠右，宽度为纸张宽度
+func copyBanner(n, copies, paperWidth int) *raster.RasterImage {
+	text := fmt.Sprintf("COPY %d/%d", n, copies)
+	banner := raster.NewRasterImage(paperWidth, 32)
+	return banner.WithDrawText(text, max(paperWidth-len(text)*16, 0), 0)
+}
@@ -0,0 +1,247 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	discoveryDialTimeout = 500 * time.Millisecond // 连接每个地址的超时时间
+	discoveryInfoTimeout = time.Second            // 等待GS I响应的时间
+	discoveryWorkers     = 64                     // 同时扫描的地址数量
+	epsonDiscoveryPort   = 3289                   // Epson ENPC
+	starDiscoveryPort    = 22222                  // Star
+)
+
+// Epson和Star打印机网卡的UDP发现请求，只使用响应的来源地址和报文前缀判断厂商
+var (
+	epsonDiscoveryQuery = []byte("EPSONQ\x03\x00\x00\x00\x00\x00")
+	starDiscoveryQuery  = []byte("STR_BCAST\x00\x00\x00\x00\x00\x00\x00\x00RQ1.0.0\x00\x00\x1c\x64\x31")
+)
+
+// GS I n 查询厂商和型号，支持的打印机返回 '_' + 字符串 + NUL
+var printerInfoQuery = []byte{0x1D, 0x49, 66, 0x1D, 0x49, 67}
+
+// DiscoveredPrinter 在局域网中找到的打印机
+type DiscoveredPrinter struct {
+	Name    string        `json:"name"`             // 建议的打印机名称
+	Address string        `json:"address"`          // IP:9100 或 usb:VID:PID[:SERIAL]
+	Vendor  string        `json:"vendor,omitempty"` // GS I 66 或广播响应得到的厂商
+	Model   string        `json:"model,omitempty"`  // GS I 67 返回的型号
+	Source  string        `json:"source"`           // 发现方式：scan、epson、star或usb
+	Config  ConfigPrinter `json:"config"`           // 可以直接添加到config.json的配置
+}
+
+// Discover 扫描本机所在的私有/24子网中开放9100端口的设备，同时广播Epson和Star的发现请求，
+// 并通过GS I查询每台设备的厂商和型号，最后加上本机连接的USB打印机。timeout为等待广播响应的时间，扫描本身约需几秒
+func Discover(timeout time.Duration) []DiscoveredPrinter {
+	var mu sync.Mutex
+	found := make(map[string]*DiscoveredPrinter) // key为IP
+	add := func(ip, source, vendor string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if p, ok := found[ip]; ok {
+			if p.Vendor == "" {
+				p.Vendor = vendor
+			}
+			if source != "scan" {
+				p.Source = source
+			}
+			return
+		}
+		found[ip] = &DiscoveredPrinter{Address: net.JoinHostPort(ip, "9100"), Vendor: vendor, Source: source}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		broadcastDiscovery(timeout, add)
+	}()
+
+	hosts := make(chan string)
+	for range discoveryWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range hosts {
+				conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, "9100"), discoveryDialTimeout)
+				if err == nil {
+					conn.Close()
+					add(ip, "scan", "")
+				}
+			}
+		}()
+	}
+	for _, ip := range localSubnetHosts() {
+		hosts <- ip
+	}
+	close(hosts)
+	wg.Wait()
+
+	// 查询厂商和型号，广播发现的设备9100端口无法连接时不能作为tcp打印机使用，同样丢弃
+	printers := make([]DiscoveredPrinter, 0, len(found))
+	for _, p := range found {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vendor, model, err := queryPrinterInfo(p.Address)
+			if err != nil {
+				return
+			}
+			if vendor != "" {
+				p.Vendor = vendor
+			}
+			p.Model = model
+			mu.Lock()
+			printers = append(printers, *p)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(printers, func(i, j int) bool {
+		return bytes.Compare(ipKey(printers[i].Address), ipKey(printers[j].Address)) < 0
+	})
+	for i := range printers {
+		p := &printers[i]
+		host, _, _ := net.SplitHostPort(p.Address)
+		p.Name = suggestName(p.Model, host[strings.LastIndexByte(host, '.')+1:])
+		p.Config = ConfigPrinter{Type: "tcp", Address: p.Address}
+	}
+	printers = append(printers, discoverUSBPrinters()...)
+	uniqueNames(printers)
+	return printers
+}
+
+// uniqueNames 为重复的名称加上序号，如不同子网中IP最后一段相同的同型号打印机，
+// 名称作为配置的key，重复时后面的打印机会覆盖前面的
+func uniqueNames(printers []DiscoveredPrinter) {
+	used := make(map[string]bool, len(printers))
+	for i := range printers {
+		name := printers[i].Name
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d", printers[i].Name, n)
+		}
+		used[name] = true
+		printers[i].Name = name
+	}
+}
+
+// localSubnetHosts 返回本机每个私有IPv4地址所在/24子网中的其他地址
+func localSubnetHosts() []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP.To4()
+		if ip == nil || !ip.IsPrivate() {
+			continue
+		}
+		for i := 1; i < 255; i++ {
+			host := net.IPv4(ip[0], ip[1], ip[2], byte(i)).String()
+			if byte(i) == ip[3] || seen[host] {
+				continue
+			}
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// broadcastDiscovery 向局域网广播Epson和Star的发现请求，在timeout内收到的响应通过add记录
+func broadcastDiscovery(timeout time.Duration, add func(ip, source, vendor string)) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for _, target := range []struct {
+		port  int
+		query []byte
+	}{
+		{epsonDiscoveryPort, epsonDiscoveryQuery},
+		{starDiscoveryPort, starDiscoveryQuery},
+	} {
+		conn.WriteToUDP(target.query, &net.UDPAddr{IP: net.IPv4bcast, Port: target.port})
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		switch {
+		case addr.Port == epsonDiscoveryPort && bytes.HasPrefix(buf[:n], []byte("EPSON")):
+			add(addr.IP.String(), "epson", "EPSON")
+		case addr.Port == starDiscoveryPort && bytes.HasPrefix(buf[:n], []byte("STR")):
+			add(addr.IP.String(), "star", "Star")
+		}
+	}
+}
+
+// queryPrinterInfo 连接打印机并通过 GS I 查询厂商和型号，不支持GS I的打印机返回空字符串
+func queryPrinterInfo(address string) (vendor, model string, err error) {
+	conn, err := net.DialTimeout("tcp", address, discoveryDialTimeout)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrPrinterOffline, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(discoveryInfoTimeout))
+	if _, err := conn.Write(printerInfoQuery); err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrPrinterOffline, err)
+	}
+
+	var resp []byte
+	buf := make([]byte, 256)
+	for bytes.Count(resp, []byte{0}) < 2 {
+		n, err := conn.Read(buf)
+		resp = append(resp, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	var info []string
+	for _, block := range bytes.Split(resp, []byte{0}) {
+		if i := bytes.IndexByte(block, '_'); i >= 0 {
+			info = append(info, strings.TrimSpace(string(block[i+1:])))
+		}
+	}
+	if len(info) > 0 {
+		vendor = info[0]
+	}
+	if len(info) > 1 {
+		model = info[1]
+	}
+	return vendor, model, nil
+}
+
+var nameCleaner = regexp.MustCompile(`[^a-z0-9]+`)
+
+// suggestName 用型号和后缀（IP的最后一段或设备名）生成打印机名称，如 tm-t20iii-101
+func suggestName(model, suffix string) string {
+	name := strings.Trim(nameCleaner.ReplaceAllString(strings.ToLower(model), "-"), "-")
+	if name == "" {
+		name = "printer"
+	}
+	return name + "-" + suffix
+}
+
+// ipKey 返回用于按IP排序的字节
+func ipKey(address string) []byte {
+	host, _, _ := net.SplitHostPort(address)
+	return net.ParseIP(host).To16()
+}
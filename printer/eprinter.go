@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/xiaohao0576/odoo-epos/raster"
 	"github.com/xiaohao0576/odoo-epos/transformer"
@@ -18,14 +20,42 @@ type EPrinter interface {
 
 type Printers = map[string]EPrinter
 
+// PaperWidth 返回打印机的纸张宽度（点），打印机没有纸张宽度（如FilePrinter）时返回0
+func PaperWidth(p EPrinter) int {
+	if pw, ok := p.(interface{ PaperWidth() int }); ok {
+		return pw.PaperWidth()
+	}
+	return 0
+}
+
 type ConfigPrinter struct {
-	Type              string `json:"type"`                // 打印机类型
-	Address           string `json:"address"`             // 打印机地址
-	PaperWidth        int    `json:"paper_width"`         // 纸张宽度
-	MarginBottom      int    `json:"margin_bottom"`       // 下边距
-	CutCommnad        string `json:"cut_command"`         // 切纸命令
-	CashDrawerCommand string `json:"cash_drawer_command"` // 钱箱命令
-	Transformer       string `json:"transformer"`         // 图像转换器
+	Type              string          `json:"type"`                          // 打印机类型
+	Address           string          `json:"address,omitempty"`             // 打印机地址
+	PaperWidth        int             `json:"paper_width,omitempty"`         // 纸张宽度
+	MarginBottom      int             `json:"margin_bottom,omitempty"`       // 下边距
+	CutCommnad        string          `json:"cut_command,omitempty"`         // 切纸命令
+	CashDrawerCommand string          `json:"cash_drawer_command,omitempty"` // 钱箱命令
+	Transformer       string          `json:"transformer,omitempty"`         // 图像转换器
+	CommandSet        string          `json:"command_set,omitempty"`         // 指令集：escpos（默认）或star
+	RasterMode        string          `json:"raster_mode,omitempty"`         // ESC/POS光栅图像编码：raster（GS v 0，默认）、graphics（GS ( L）或column（ESC *）
+	Compress          bool            `json:"compress,omitempty"`            // 减少光栅数据：连续的空白行用走纸指令代替，去掉右侧空白，不是游程压缩
+	TwoColor          bool            `json:"two_color,omitempty"`           // 是否支持双色（红/黑）打印
+	QueueSize         int             `json:"queue_size,omitempty"`          // 打印队列长度
+	SpoolDir          string          `json:"spool_dir,omitempty"`           // 磁盘队列目录，默认为配置文件所在目录下的 spool/<打印机名称>
+	SpoolMaxAge       int             `json:"spool_max_age,omitempty"`       // 离线任务的最长保留时间（分钟），默认60分钟
+	Members           []string        `json:"members,omitempty"`             // 打印机组或镜像打印机的成员名称（type为group或mirror时）
+	Mode              string          `json:"mode,omitempty"`                // 打印机组的工作模式：failover或round_robin
+	Persistent        bool            `json:"persistent,omitempty"`          // tcp打印机在任务之间保持连接
+	ConnectTimeout    int             `json:"connect_timeout,omitempty"`     // tcp连接超时（秒），默认5秒
+	WriteTimeout      int             `json:"write_timeout,omitempty"`       // tcp每次写入的超时（秒），默认10秒
+	KeepAlive         int             `json:"keep_alive,omitempty"`          // tcp keepalive探测间隔（秒），默认30秒
+	IdleTimeout       int             `json:"idle_timeout,omitempty"`        // 持久连接空闲多久后断开（秒），默认30秒，-1为不断开
+	Completion        string          `json:"completion,omitempty"`          // usb和serial打印机确认打印完成的方式：sleep（默认）、received或printed
+	Fit               string          `json:"fit,omitempty"`                 // 图像比纸张宽时的处理方式：crop（默认）、scale、trim_scale或reflow
+	Resample          string          `json:"resample,omitempty"`            // 缩放图像的重采样方式：area（默认）或nearest
+	Copies            int             `json:"copies,omitempty"`              // 默认打印份数，请求中没有指定份数时使用
+	CopyBanner        bool            `json:"copy_banner,omitempty"`         // 多份打印时每份前面打印 "COPY n/N"
+	Profile           *PrinterProfile `json:"profile,omitempty"`             // 每个任务开始时发送的打印机设置
 }
 
 func (c *ConfigPrinter) NewPrinter() EPrinter {
@@ -38,13 +68,26 @@ func (c *ConfigPrinter) NewPrinter() EPrinter {
 		c.MarginBottom = 120 // 默认下边距
 	}
 
+	newCommands, ok := CommandSets[c.CommandSet]
+	if !ok {
+		newCommands = CommandSets["escpos"] // 默认使用ESC/POS指令集
+	}
+	commands := newCommands(c)
+
 	cutCommand, err := hex.DecodeString(c.CutCommnad)
 	if err != nil || len(cutCommand) == 0 {
-		cutCommand = []byte{0x1D, 0x56, 0x01} // 默认半切纸命令
+		cutCommand = commands.Cut() // 指令集的默认切纸命令
 	}
 	cashDrawerCommand, err := hex.DecodeString(c.CashDrawerCommand)
 	if err != nil || len(cashDrawerCommand) == 0 {
-		cashDrawerCommand = []byte{0x1B, 0x70, 0x00, 0x19, 0xFA} // 默认钱箱命令
+		cashDrawerCommand = commands.CashDrawer() // 指令集的默认钱箱命令
+	}
+
+	fit := newFitFunc(c.Fit, c.PaperWidth, c.Resample)
+
+	bannerWidth := 0
+	if c.CopyBanner {
+		bannerWidth = c.PaperWidth // 份数标题与纸张同宽
 	}
 
 	transfer, ok := transformer.Transformers[c.Transformer]
@@ -61,15 +104,29 @@ func (c *ConfigPrinter) NewPrinter() EPrinter {
 			cutCommand:        cutCommand,        // 切纸命令
 			cashDrawerCommand: cashDrawerCommand, // 钱箱命令
 			transformer:       transfer,          // 图像转换器
+			twoColor:          c.TwoColor,        // 双色打印
+			commands:          commands,          // 指令集
+			fit:               fit,               // 调整图像到纸张宽度
+			bannerWidth:       bannerWidth,       // 份数标题的宽度
+			completion:        c.Completion,      // 确认打印完成的方式
 		}
 	case "tcp":
 		return &TCPPrinter{
-			paperWidth:        c.PaperWidth,      // 纸张宽度
-			marginBottom:      c.MarginBottom,    // 下边距
-			HostPort:          c.Address,         // 打印机地址
-			cutCommand:        cutCommand,        // 切纸命令
-			cashDrawerCommand: cashDrawerCommand, // 钱箱命令
-			transformer:       transfer,          // 图像转换器
+			paperWidth:        c.PaperWidth,                                  // 纸张宽度
+			marginBottom:      c.MarginBottom,                                // 下边距
+			HostPort:          c.Address,                                     // 打印机地址
+			cutCommand:        cutCommand,                                    // 切纸命令
+			cashDrawerCommand: cashDrawerCommand,                             // 钱箱命令
+			transformer:       transfer,                                      // 图像转换器
+			twoColor:          c.TwoColor,                                    // 双色打印
+			commands:          commands,                                      // 指令集
+			fit:               fit,                                           // 调整图像到纸张宽度
+			bannerWidth:       bannerWidth,                                   // 份数标题的宽度
+			persistent:        c.Persistent,                                  // 任务之间保持连接
+			connectTimeout:    time.Duration(c.ConnectTimeout) * time.Second, // 连接超时
+			writeTimeout:      time.Duration(c.WriteTimeout) * time.Second,   // 每次写入的超时
+			keepAlive:         time.Duration(c.KeepAlive) * time.Second,      // TCP keepalive探测间隔
+			idleTimeout:       time.Duration(c.IdleTimeout) * time.Second,    // 持久连接的空闲时间
 		}
 	case "serial":
 		return &SerialPrinter{
@@ -79,20 +136,54 @@ func (c *ConfigPrinter) NewPrinter() EPrinter {
 			cutCommand:        cutCommand,        // 切纸命令
 			cashDrawerCommand: cashDrawerCommand, // 钱箱命令
 			transformer:       transfer,          // 图像转换器
+			twoColor:          c.TwoColor,        // 双色打印
+			commands:          commands,          // 指令集
+			fit:               fit,               // 调整图像到纸张宽度
+			bannerWidth:       bannerWidth,       // 份数标题的宽度
+			completion:        c.Completion,      // 确认打印完成的方式
 		}
 	case "file":
 		return &FilePrinter{
-			dir:         c.Address, // 文件保存目录
-			transformer: transfer,  // 图像转换器
+			dir:         c.Address,    // 文件保存目录
+			paperWidth:  c.PaperWidth, // 纸张宽度
+			transformer: transfer,     // 图像转换器
 		}
 	default:
 		return nil // 未知类型
 	}
 }
 
+// newSpool 为打印机创建打印队列，未完成的任务保存在磁盘上，打印机离线时自动重试
+func newSpool(configFile, name string, printer EPrinter, config ConfigPrinter) *SpoolPrinter {
+	spoolDir := config.SpoolDir
+	if spoolDir == "" {
+		spoolDir = filepath.Join(filepath.Dir(configFile), "spool", name)
+	}
+	spool, err := NewDurableSpoolPrinter(name, printer, config.QueueSize, spoolDir, time.Duration(config.SpoolMaxAge)*time.Minute)
+	if err != nil {
+		fmt.Printf("Disk spool disabled for %s: %v\n", name, err)
+		spool = NewSpoolPrinter(name, printer, config.QueueSize)
+	}
+	spool.config = config // 热加载时用于判断配置是否变化
+	return spool
+}
+
 // 读取并解析 config.json 到 Printers
 func LoadPrinters(filename string) (Printers, error) {
-	var printers Printers = make(map[string]EPrinter)
+	configPrinters, err := ReadConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	printers := buildPrinters(filename, configPrinters, nil)
+	if len(printers) == 0 {
+		fmt.Println("No printers configured")
+		return nil, fmt.Errorf("no printers configured or all failed to open")
+	}
+	return printers, nil
+}
+
+// ReadConfig 读取配置文件
+func ReadConfig(filename string) (map[string]ConfigPrinter, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		fmt.Printf("Error opening config file: %v\n", err)
@@ -106,17 +197,96 @@ func LoadPrinters(filename string) (Printers, error) {
 		fmt.Printf("Error decoding config file: %v\n", err)
 		return nil, err
 	}
+	return configPrinters, nil
+}
+
+// SaveConfig 保存配置文件，先写入临时文件再替换，避免写入一半时被读取
+func SaveConfig(filename string, configPrinters map[string]ConfigPrinter) error {
+	data, err := json.MarshalIndent(configPrinters, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// buildPrinters 根据配置创建打印机，current中的打印机可以直接复用
+func buildPrinters(filename string, configPrinters map[string]ConfigPrinter, current Printers) Printers {
+	var printers Printers = make(map[string]EPrinter)
+	// 先创建所有打印机，再创建引用它们的打印机组和镜像打印机
 	for name, config := range configPrinters {
-		printer := config.NewPrinter()
+		if config.Type == "group" || config.Type == "mirror" {
+			continue
+		}
+		if printer, ok := current[name]; ok {
+			printers[name] = printer
+			continue
+		}
+		c := config // NewPrinter会设置默认值，保留原始配置用于热加载时比较
+		printer := c.NewPrinter()
 		if printer == nil {
 			fmt.Printf("Unknown printer type for %s: %s\n", name, config.Type)
 			continue
 		}
-		printers[name] = printer
+		printers[name] = newSpool(filename, name, printer, config)
 	}
-	if len(printers) == 0 {
-		fmt.Println("No printers configured")
-		return nil, fmt.Errorf("no printers configured or all failed to open")
+	for name, config := range configPrinters {
+		if config.Type != "group" {
+			continue
+		}
+		if printer, ok := current[name]; ok {
+			printers[name] = printer
+			continue
+		}
+		var names []string
+		var members []EPrinter
+		for _, member := range config.Members {
+			spool, ok := printers[member].(*SpoolPrinter)
+			if !ok || configPrinters[member].Type == "group" {
+				fmt.Printf("Unknown member %s in printer group %s\n", member, name)
+				continue
+			}
+			// 成员失败时由打印机组切换到下一台，打印机组自己的队列负责离线重试
+			names = append(names, member)
+			members = append(members, spool.NoRetry())
+		}
+		if len(members) == 0 {
+			fmt.Printf("Printer group %s has no members\n", name)
+			continue
+		}
+		printers[name] = newSpool(filename, name, NewGroupPrinter(config.Mode, names, members), config)
 	}
-	return printers, nil
+	for name, config := range configPrinters {
+		if config.Type != "mirror" {
+			continue
+		}
+		if printer, ok := current[name]; ok {
+			printers[name] = printer
+			continue
+		}
+		var names []string
+		var members []EPrinter
+		for _, member := range config.Members {
+			spool, ok := printers[member].(*SpoolPrinter)
+			if !ok || configPrinters[member].Type == "mirror" {
+				fmt.Printf("Unknown member %s in mirror printer %s\n", member, name)
+				continue
+			}
+			// 成员任务的打印历史由镜像打印机记录，避免同一个任务记录多次
+			names = append(names, member)
+			members = append(members, spool.Member())
+		}
+		if len(members) == 0 {
+			fmt.Printf("Mirror printer %s has no members\n", name)
+			continue
+		}
+		// 每台成员有自己的队列负责离线重试，镜像打印机不保存到磁盘，避免重复打印已成功的成员
+		spool := NewSpoolPrinter(name, NewMirrorPrinter(names, members), config.QueueSize)
+		spool.config = config
+		printers[name] = spool
+	}
+	return printers
 }
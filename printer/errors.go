@@ -0,0 +1,31 @@
+package printer
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// 打印机错误，打印机实现应使用 %w 包装这些错误，便于调用方判断错误类型
+var (
+	ErrPrinterOffline = errors.New("printer offline")      // 无法连接或打开打印机
+	ErrCoverOpen      = errors.New("printer cover open")   // 上盖打开
+	ErrPaperEnd       = errors.New("printer paper end")    // 缺纸
+	ErrAutoCutter     = errors.New("printer cutter error") // 切刀错误
+	ErrTimeout        = errors.New("printer timeout")      // 通讯超时
+)
+
+// IsTimeout 判断错误是否为超时错误（包括网络超时）
+func IsTimeout(err error) bool {
+	if errors.Is(err, ErrTimeout) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsRetryable 判断错误是否可以在打印机恢复后重试（离线、超时、上盖打开、缺纸）
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrPrinterOffline) || errors.Is(err, ErrCoverOpen) ||
+		errors.Is(err, ErrPaperEnd) || IsTimeout(err)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/xiaohao0576/odoo-epos/raster"
@@ -12,6 +13,7 @@ import (
 
 type FilePrinter struct {
 	dir         string                      // 文件保存目录
+	paperWidth  int                         // 模拟打印原始指令时的纸张宽度
 	transformer transformer.TransformerFunc // 用于转换图像的转换器
 }
 
@@ -19,6 +21,14 @@ func (p FilePrinter) String() string {
 	return fmt.Sprintf("FilePrinter{Dir: %s}", p.dir)
 }
 
+// Status 保存目录存在时打印机在线
+func (p FilePrinter) Status() (PrinterStatus, error) {
+	if info, err := os.Stat(p.dir); err != nil || !info.IsDir() {
+		return PrinterStatus{}, fmt.Errorf("%w: directory %s not found", ErrPrinterOffline, p.dir)
+	}
+	return PrinterStatus{Online: true}, nil
+}
+
 func (p FilePrinter) OpenCashBox() error {
 	return nil // 文件打印机不支持打开钱箱
 }
@@ -34,11 +44,29 @@ func (p FilePrinter) PrintRasterImage(img *raster.RasterImage) error {
 	if img == nil {
 		return nil // 如果转换器返回 nil，表示不需要保存图像
 	}
-	return img.SaveToPngFile(filename)
+	return savePages(img, filename)
 }
 
+// savePages 保存图像，多页图像每页保存为一个文件，文件名后加页码
+func savePages(img *raster.RasterImage, filename string) error {
+	pages := img.CutPages()
+	if len(pages) == 1 {
+		return img.SaveToPngFile(filename)
+	}
+	ext := filepath.Ext(filename)
+	for i, page := range pages {
+		pageFilename := fmt.Sprintf("%s-%d%s", strings.TrimSuffix(filename, ext), i+1, ext)
+		if err := page.SaveToPngFile(pageFilename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintRaw 保存原始指令，同时用ESC/POS模拟打印机将指令绘制为同名的png文件以便查看
 func (p FilePrinter) PrintRaw(data []byte) error {
-	filename := fmt.Sprintf("%s/%s.bin", p.dir, time.Now().Format("20060102-150405"))
+	basename := fmt.Sprintf("%s/%s", p.dir, time.Now().Format("20060102-150405"))
+	filename := basename + ".bin"
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create file %s: %w", filename, err)
@@ -48,5 +76,8 @@ func (p FilePrinter) PrintRaw(data []byte) error {
 	if _, err := file.Write(data); err != nil {
 		return fmt.Errorf("failed to write data to file %s: %w", filename, err)
 	}
+	if img := raster.NewRasterImageFromEscPos(data, p.paperWidth); img != nil {
+		return savePages(img, basename+".png")
+	}
 	return nil
 }
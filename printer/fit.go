@@ -0,0 +1,49 @@
+package printer
+
+import "github.com/xiaohao0576/odoo-epos/raster"
+
+// FitModes 图像比纸张宽时的处理方式，可以在配置的fit中选择，未配置时使用crop。
+// resample为缩放时的重采样方式，见raster.ResampleNearest和raster.ResampleArea
+var FitModes = map[string]func(page *raster.RasterImage, paperWidth int, resample string) *raster.RasterImage{
+	// 按对齐方式裁掉超出纸张的部分
+	"crop": func(page *raster.RasterImage, paperWidth int, resample string) *raster.RasterImage {
+		return page.WithCropWidth(paperWidth)
+	},
+	// 整页按比例缩小到纸张宽度
+	"scale": func(page *raster.RasterImage, paperWidth int, resample string) *raster.RasterImage {
+		return page.WithScaleWidth(paperWidth, resample)
+	},
+	// 先去掉左右空白，内容仍然比纸张宽时再缩小，适合两侧留白较多的小票
+	"trim_scale": func(page *raster.RasterImage, paperWidth int, resample string) *raster.RasterImage {
+		page = page.WithTrimMarginX()
+		if page.Width <= paperWidth {
+			return page
+		}
+		return page.WithScaleWidth(paperWidth, resample)
+	},
+	// 文字行按词重新换行排到纸张宽度，文字大小不变，单个词仍然太宽时缩小
+	"reflow": func(page *raster.RasterImage, paperWidth int, resample string) *raster.RasterImage {
+		return page.WithReflow(paperWidth, resample)
+	},
+}
+
+// fitFunc 把一页图像调整到纸张宽度
+type fitFunc func(page *raster.RasterImage) *raster.RasterImage
+
+// newFitFunc 返回把每页图像调整到纸张宽度的函数，图像不比纸张宽时不做处理，resample未配置时使用区域平均
+func newFitFunc(mode string, paperWidth int, resample string) fitFunc {
+	fit, ok := FitModes[mode]
+	if !ok {
+		fit = FitModes["crop"] // 默认裁剪
+	}
+	if resample == "" {
+		resample = raster.ResampleArea
+	}
+	paperWidth &^= 7 // 光栅图像的宽度是8的倍数
+	return func(page *raster.RasterImage) *raster.RasterImage {
+		if page.Width <= paperWidth {
+			return page
+		}
+		return fit(page, paperWidth, resample)
+	}
+}
@@ -0,0 +1,149 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// 打印机组的工作模式
+const (
+	GroupFailover   = "failover"    // 按顺序使用第一台可用的打印机
+	GroupRoundRobin = "round_robin" // 轮流使用各台打印机，失败时使用下一台
+)
+
+// GroupPrinter 打印机组，成员为config.json中的其他打印机，
+// 成员出错或离线时自动切换到下一台，Odoo中的打印机地址不需要修改
+type GroupPrinter struct {
+	mode    string
+	names   []string      // 成员名称
+	members []EPrinter    // 成员打印机
+	failed  []atomic.Bool // 成员上次打印是否失败，失败过的成员下次打印前先查询状态
+	next    atomic.Uint64
+}
+
+func NewGroupPrinter(mode string, names []string, members []EPrinter) *GroupPrinter {
+	if mode != GroupRoundRobin {
+		mode = GroupFailover
+	}
+	return &GroupPrinter{
+		mode:    mode,
+		names:   names,
+		members: members,
+		failed:  make([]atomic.Bool, len(members)),
+	}
+}
+
+func (g *GroupPrinter) String() string {
+	return fmt.Sprintf("GroupPrinter{mode: %s, members: %s}", g.mode, strings.Join(g.names, ", "))
+}
+
+// PaperWidth 返回第一台成员的纸张宽度
+func (g *GroupPrinter) PaperWidth() int {
+	if len(g.members) == 0 {
+		return 0
+	}
+	return PaperWidth(g.members[0])
+}
+
+// Status 返回第一台可以打印的成员的状态，都不可用时返回第一台成员的状态
+func (g *GroupPrinter) Status() (PrinterStatus, error) {
+	var first PrinterStatus
+	var firstErr error = ErrStatusUnsupported
+	for i, member := range g.members {
+		status, err := Status(member)
+		if err == nil && status.Err() == nil {
+			return status, nil
+		}
+		if i == 0 {
+			first, firstErr = status, err
+		}
+	}
+	return first, firstErr
+}
+
+func (g *GroupPrinter) OpenCashBox() error {
+	return g.try(func(p EPrinter) error {
+		return p.OpenCashBox()
+	})
+}
+
+func (g *GroupPrinter) PrintRasterImage(img *raster.RasterImage) error {
+	return g.try(func(p EPrinter) error {
+		return p.PrintRasterImage(img)
+	})
+}
+
+func (g *GroupPrinter) PrintRaw(data []byte) error {
+	return g.try(func(p EPrinter) error {
+		return p.PrintRaw(data)
+	})
+}
+
+func (g *GroupPrinter) PrintRasterCopies(img *raster.RasterImage, copies int) error {
+	return g.try(func(p EPrinter) error {
+		return printRasterCopies(p, img, copies)
+	})
+}
+
+func (g *GroupPrinter) PrintRawCopies(data []byte, copies int) error {
+	return g.try(func(p EPrinter) error {
+		return printRawCopies(p, data, copies)
+	})
+}
+
+func (g *GroupPrinter) PrintEscPosCopies(data []byte, copies int) error {
+	return g.try(func(p EPrinter) error {
+		return printEscPosCopies(p, data, copies)
+	})
+}
+
+// order 返回本次尝试成员的顺序
+func (g *GroupPrinter) order() []int {
+	n := len(g.members)
+	start := 0
+	if g.mode == GroupRoundRobin && n > 0 {
+		start = int((g.next.Add(1) - 1) % uint64(n))
+	}
+	order := make([]int, n)
+	for i := range order {
+		order[i] = (start + i) % n
+	}
+	return order
+}
+
+// try 依次在成员上执行打印，成功即返回，全部失败时返回所有成员的错误。
+// 上次打印失败的成员先查询状态，上盖打开、缺纸或切刀错误时直接跳过；
+// 不支持状态查询或查询超时的成员仍然尝试打印。正常的成员不查询状态，避免每个任务多一次连接和等待
+func (g *GroupPrinter) try(fn func(EPrinter) error) error {
+	if len(g.members) == 0 {
+		return fmt.Errorf("%w: printer group has no members", ErrPrinterOffline)
+	}
+	var errs []error
+	for _, i := range g.order() {
+		member := g.members[i]
+		var err error
+		if g.failed[i].Load() {
+			var status PrinterStatus
+			status, err = Status(member)
+			if err == nil {
+				err = status.Err()
+			} else if !errors.Is(err, ErrPrinterOffline) {
+				err = nil
+			}
+		}
+		if err == nil {
+			err = fn(member)
+		}
+		g.failed[i].Store(err != nil)
+		if err == nil {
+			return nil
+		}
+		fmt.Printf("Group member %s failed: %v\n", g.names[i], err)
+		errs = append(errs, fmt.Errorf("%s: %w", g.names[i], err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,295 @@
+package printer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/xiaohao0576/odoo-epos/raster"
+	"github.com/xiaohao0576/odoo-epos/transformer"
+)
+
+var (
+	ErrHistoryNotFound       = errors.New("history record not found")
+	ErrHistoryNotReprintable = errors.New("cash drawer jobs can not be reprinted")
+)
+
+// HistoryRecord 打印历史中的一条记录
+type HistoryRecord struct {
+	ID          string             `json:"id"`
+	Printer     string             `json:"printer"`
+	Source      string             `json:"source,omitempty"` // 请求来源IP
+	Kind        JobKind            `json:"kind"`
+	Copies      int                `json:"copies,omitempty"`
+	EscPos      bool               `json:"escpos,omitempty"` // ePOS文档转换的ESC/POS指令
+	Transformer string             `json:"transformer,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	FinishedAt  time.Time          `json:"finished_at"`
+	Success     bool               `json:"success"`
+	Error       string             `json:"error,omitempty"`
+	Preview     bool               `json:"preview,omitempty"` // 是否有PNG预览
+	Align       string             `json:"align,omitempty"`   // 光栅图像的对齐方式
+	Color       string             `json:"color,omitempty"`   // 光栅图像的颜色
+	Bands       []raster.ColorBand `json:"bands,omitempty"`   // 光栅图像中颜色不同的各段
+}
+
+// HistoryFilter 查询打印历史的条件，空值表示不限制
+type HistoryFilter struct {
+	Printer string
+	Source  string
+	Query   string    // 在ID、打印机、来源和错误信息中搜索
+	Success *bool     // 只返回成功或失败的记录
+	From    time.Time // 创建时间下限
+	To      time.Time // 创建时间上限
+	Limit   int       // 最多返回的记录数
+}
+
+// History 将所有打印任务保存在本地目录中，按日期分目录：
+// <dir>/<YYYYMMDD>/<id>.json 保存记录，<id>.png 保存原始图像（用于重新打印，原始指令任务为模拟打印的预览），
+// <id>-final.png 保存经过转换器处理的图像（用于预览），<id>.bin 保存原始指令
+type History struct {
+	dir       string
+	retention time.Duration
+}
+
+var jobHistory atomic.Pointer[History]
+
+// SetHistory 设置所有打印队列使用的打印历史，为nil时不记录
+func SetHistory(h *History) {
+	jobHistory.Store(h)
+}
+
+// OpenHistory 打开打印历史目录并定期删除超过retention的记录
+func OpenHistory(dir string, retention time.Duration) (*History, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history dir %s: %w", dir, err)
+	}
+	h := &History{dir: dir, retention: retention}
+	go func() {
+		for {
+			h.cleanup()
+			time.Sleep(time.Hour)
+		}
+	}()
+	return h, nil
+}
+
+// dayDir 返回记录所在的日期目录，任务ID以创建时间开头
+func (h *History) dayDir(id string) string {
+	if len(id) < 8 {
+		return filepath.Join(h.dir, "unknown")
+	}
+	return filepath.Join(h.dir, id[:8])
+}
+
+func (h *History) path(id, suffix string) string {
+	return filepath.Join(h.dayDir(id), filepath.Base(id)+suffix)
+}
+
+// Record 保存已完成的任务，config为打印机的配置，用于生成与打印结果一致的预览
+func (h *History) Record(job *Job, config ConfigPrinter) {
+	transformerName := config.Transformer
+	if err := os.MkdirAll(h.dayDir(job.ID), 0755); err != nil {
+		fmt.Println("Failed to record job history:", err)
+		return
+	}
+	record := HistoryRecord{
+		ID:          job.ID,
+		Printer:     job.Printer,
+		Source:      job.Source,
+		Kind:        job.Kind,
+		Copies:      job.Copies,
+		EscPos:      job.EscPos,
+		Transformer: transformerName,
+		CreatedAt:   job.CreatedAt,
+		FinishedAt:  job.FinishedAt,
+		Success:     job.err == nil,
+		Error:       job.Error,
+	}
+	switch job.Kind {
+	case JobRaster:
+		if err := job.Image.SaveToPngFile(h.path(job.ID, ".png")); err != nil {
+			fmt.Println("Failed to save job image:", err)
+			break
+		}
+		record.Preview = true
+		record.Align = job.Image.Align
+		record.Color = job.Image.Color
+		record.Bands = job.Image.Bands
+		// 保存转换器处理后的图像，与打印出来的小票一致
+		if transfer, ok := transformer.Transformers[transformerName]; ok && transformerName != "" {
+			if final := transfer(job.Image.Clone()); final != nil {
+				final.SaveToPngFile(h.path(job.ID, "-final.png"))
+			}
+		}
+	case JobRaw:
+		if err := os.WriteFile(h.path(job.ID, ".bin"), job.Data, 0644); err != nil {
+			fmt.Println("Failed to save job data:", err)
+			break
+		}
+		// 原始指令用ESC/POS模拟打印机绘制预览，重新打印仍然使用原始指令
+		if img := raster.NewRasterImageFromEscPos(job.Data, config.PaperWidth); img != nil {
+			record.Preview = img.SaveToPngFile(h.path(job.ID, ".png")) == nil
+		}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(h.path(job.ID, ".json"), data, 0644); err != nil {
+		fmt.Println("Failed to record job history:", err)
+	}
+}
+
+// validID 任务ID只包含数字和'-'，避免通过ID访问历史目录以外的文件
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Get 返回一条记录
+func (h *History) Get(id string) (HistoryRecord, error) {
+	var record HistoryRecord
+	if !validID(id) {
+		return record, ErrHistoryNotFound
+	}
+	data, err := os.ReadFile(h.path(id, ".json"))
+	if err != nil {
+		return record, ErrHistoryNotFound
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// List 按创建时间从新到旧返回符合条件的记录
+func (h *History) List(filter HistoryFilter) []HistoryRecord {
+	days, _ := os.ReadDir(h.dir)
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Name() > days[j].Name()
+	})
+	records := []HistoryRecord{}
+	for _, day := range days {
+		if !day.IsDir() {
+			continue
+		}
+		var dayRecords []HistoryRecord
+		files, _ := os.ReadDir(filepath.Join(h.dir, day.Name()))
+		for _, file := range files {
+			id, ok := strings.CutSuffix(file.Name(), ".json")
+			if !ok {
+				continue
+			}
+			record, err := h.Get(id)
+			if err == nil && filter.match(record) {
+				dayRecords = append(dayRecords, record)
+			}
+		}
+		sort.Slice(dayRecords, func(i, j int) bool {
+			return dayRecords[i].CreatedAt.After(dayRecords[j].CreatedAt)
+		})
+		records = append(records, dayRecords...)
+		if filter.Limit > 0 && len(records) >= filter.Limit {
+			return records[:filter.Limit]
+		}
+	}
+	return records
+}
+
+func (f HistoryFilter) match(r HistoryRecord) bool {
+	switch {
+	case f.Printer != "" && r.Printer != f.Printer:
+		return false
+	case f.Source != "" && !strings.HasPrefix(r.Source, f.Source):
+		return false
+	case f.Success != nil && r.Success != *f.Success:
+		return false
+	case !f.From.IsZero() && r.CreatedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && r.CreatedAt.After(f.To):
+		return false
+	case f.Query != "":
+		q := strings.ToLower(f.Query)
+		for _, s := range []string{r.ID, r.Printer, r.Source, r.Error} {
+			if strings.Contains(strings.ToLower(s), q) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// PreviewFile 返回预览图片的路径，优先使用转换器处理后的图像
+func (h *History) PreviewFile(id string) (string, error) {
+	if !validID(id) {
+		return "", ErrHistoryNotFound
+	}
+	for _, suffix := range []string{"-final.png", ".png"} {
+		if _, err := os.Stat(h.path(id, suffix)); err == nil {
+			return h.path(id, suffix), nil
+		}
+	}
+	return "", ErrHistoryNotFound
+}
+
+// Job 使用记录中的原始数据创建一个新任务，用于重新打印
+func (h *History) Job(id string) (*Job, error) {
+	record, err := h.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch record.Kind {
+	case JobRaster:
+		img := raster.NewRasterImageFromFile(h.path(id, ".png"))
+		if img == nil {
+			return nil, ErrHistoryNotFound
+		}
+		img.Align = record.Align
+		img.Color = record.Color
+		img.Bands = record.Bands
+		job := NewRasterJob(img)
+		job.Copies = record.Copies
+		return job, nil
+	case JobRaw:
+		data, err := os.ReadFile(h.path(id, ".bin"))
+		if err != nil {
+			return nil, ErrHistoryNotFound
+		}
+		job := NewRawJob(data)
+		job.EscPos = record.EscPos
+		job.Copies = record.Copies
+		return job, nil
+	default:
+		// 重新打印钱箱任务会再次打开钱箱
+		return nil, ErrHistoryNotReprintable
+	}
+}
+
+// cleanup 删除超过保留时间的日期目录
+func (h *History) cleanup() {
+	if h.retention <= 0 {
+		return
+	}
+	oldest := time.Now().Add(-h.retention).Format("20060102")
+	days, _ := os.ReadDir(h.dir)
+	for _, day := range days {
+		if day.IsDir() && day.Name() < oldest {
+			os.RemoveAll(filepath.Join(h.dir, day.Name()))
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// MirrorPrinter 镜像打印机，同一个任务同时发送给所有成员，
+// 每台成员使用自己的转换器，例如厨房打印机打印的同时用FilePrinter存档
+type MirrorPrinter struct {
+	names   []string   // 成员名称
+	members []EPrinter // 成员打印机
+}
+
+// MemberResult 镜像打印机一台成员的打印结果
+type MemberResult struct {
+	Printer  string `json:"printer"`
+	Success  bool   `json:"success"`
+	Deferred bool   `json:"deferred,omitempty"` // 成员离线，任务已保存在成员的队列中等待重试
+	Error    string `json:"error,omitempty"`
+	err      error
+}
+
+// MirrorError 镜像打印机有成员没有打印成功时返回的错误，包含每台成员的结果。
+// 有成员打印失败时包装失败的错误，只有离线重试的成员时包装 ErrJobDeferred
+type MirrorError struct {
+	Results []MemberResult
+}
+
+func (e *MirrorError) Error() string {
+	var parts []string
+	for _, r := range e.Results {
+		switch {
+		case r.Success:
+			parts = append(parts, r.Printer+": ok")
+		default:
+			parts = append(parts, r.Printer+": "+r.Error)
+		}
+	}
+	return "mirror: " + strings.Join(parts, "; ")
+}
+
+func (e *MirrorError) Unwrap() []error {
+	var failed, deferred []error
+	for _, r := range e.Results {
+		switch {
+		case r.Success:
+		case r.Deferred:
+			deferred = append(deferred, r.err)
+		default:
+			failed = append(failed, r.err)
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return deferred
+}
+
+func NewMirrorPrinter(names []string, members []EPrinter) *MirrorPrinter {
+	return &MirrorPrinter{
+		names:   names,
+		members: members,
+	}
+}
+
+func (m *MirrorPrinter) String() string {
+	return fmt.Sprintf("MirrorPrinter{members: %s}", strings.Join(m.names, ", "))
+}
+
+// PaperWidth 返回第一台成员的纸张宽度
+func (m *MirrorPrinter) PaperWidth() int {
+	if len(m.members) == 0 {
+		return 0
+	}
+	return PaperWidth(m.members[0])
+}
+
+func (m *MirrorPrinter) OpenCashBox() error {
+	return m.each(func(p EPrinter) error {
+		return p.OpenCashBox()
+	})
+}
+
+func (m *MirrorPrinter) PrintRasterImage(img *raster.RasterImage) error {
+	return m.each(func(p EPrinter) error {
+		return p.PrintRasterImage(img)
+	})
+}
+
+func (m *MirrorPrinter) PrintRaw(data []byte) error {
+	return m.each(func(p EPrinter) error {
+		return p.PrintRaw(data)
+	})
+}
+
+func (m *MirrorPrinter) PrintRasterCopies(img *raster.RasterImage, copies int) error {
+	return m.each(func(p EPrinter) error {
+		return printRasterCopies(p, img, copies)
+	})
+}
+
+func (m *MirrorPrinter) PrintRawCopies(data []byte, copies int) error {
+	return m.each(func(p EPrinter) error {
+		return printRawCopies(p, data, copies)
+	})
+}
+
+func (m *MirrorPrinter) PrintEscPosCopies(data []byte, copies int) error {
+	return m.each(func(p EPrinter) error {
+		return printEscPosCopies(p, data, copies)
+	})
+}
+
+// each 在所有成员上同时执行打印，有成员没有打印成功时返回 *MirrorError
+func (m *MirrorPrinter) each(fn func(EPrinter) error) error {
+	results := make([]MemberResult, len(m.members))
+	var wg sync.WaitGroup
+	for i, member := range m.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := fn(member)
+			results[i] = MemberResult{Printer: m.names[i], Success: err == nil, err: err}
+			if err != nil {
+				results[i].Deferred = errors.Is(err, ErrJobDeferred)
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	for _, r := range results {
+		if !r.Success {
+			return &MirrorError{Results: results}
+		}
+	}
+	return nil
+}
+
+// MirrorResults 返回镜像打印机每台成员的打印结果，printer不是镜像打印机时返回nil
+func MirrorResults(printer EPrinter, err error) []MemberResult {
+	var mirrorErr *MirrorError
+	if errors.As(err, &mirrorErr) {
+		return mirrorErr.Results
+	}
+	if spool, ok := printer.(*SpoolPrinter); ok {
+		printer = spool.Printer()
+	}
+	mirror, ok := printer.(*MirrorPrinter)
+	if !ok || err != nil {
+		return nil
+	}
+	results := make([]MemberResult, len(mirror.names))
+	for i, name := range mirror.names {
+		results[i] = MemberResult{Printer: name, Success: true}
+	}
+	return results
+}
@@ -0,0 +1,249 @@
+// Package printertest 提供一个进程内的模拟网络打印机（端口9100），用于在没有硬件的情况下测试
+// TCPPrinter、打印队列和HTTP处理函数。模拟打印机记录收到的数据，按设定的状态响应DLE EOT和GS a，
+// 并可以模拟慢速打印机、不响应状态查询和连接被重置
+package printertest
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// Behavior 模拟打印机的行为，修改后对新的数据和查询立即生效。
+// 重置连接时已经写入系统发送缓冲区的数据不会返回错误，测试写入失败时需要发送足够多的数据或同时设置Delay
+type Behavior struct {
+	Status     eprinter.PrinterStatus // DLE EOT和GS a返回的状态
+	NoResponse bool                   // 不响应状态查询，模拟不支持状态查询或没有响应的打印机
+	Delay      time.Duration          // 每次读取数据和响应状态查询前等待的时间，模拟慢速打印机
+	ResetAfter int                    // 收到ResetAfter字节后重置连接，负数表示连接后立即重置，0表示不重置
+}
+
+// Job 一个连接中收到的打印数据
+type Job struct {
+	Data  []byte              // 收到的所有数据，包括状态查询指令
+	Image *raster.RasterImage // 模拟打印的结果，没有打印内容时为nil
+	Reset bool                // 连接是否被模拟打印机重置
+}
+
+// Server 模拟网络打印机
+type Server struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	behavior Behavior
+	conns    map[net.Conn]bool
+	jobs     []Job
+	queries  int
+}
+
+// NewServer 在127.0.0.1的随机端口上启动一台在线的模拟打印机，无法监听时panic
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("printertest: failed to listen: %v", err))
+	}
+	s := &Server{
+		listener: listener,
+		behavior: Behavior{Status: eprinter.PrinterStatus{Online: true}},
+		conns:    make(map[net.Conn]bool),
+	}
+	go s.serve()
+	return s
+}
+
+// Addr 返回模拟打印机的地址
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Config 返回连接模拟打印机的tcp打印机配置
+func (s *Server) Config() eprinter.ConfigPrinter {
+	return eprinter.ConfigPrinter{Type: "tcp", Address: s.Addr()}
+}
+
+// SetBehavior 修改模拟打印机的行为
+func (s *Server) SetBehavior(b Behavior) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.behavior = b
+}
+
+// SetStatus 只修改模拟打印机返回的状态
+func (s *Server) SetStatus(status eprinter.PrinterStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.behavior.Status = status
+}
+
+func (s *Server) currentBehavior() Behavior {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.behavior
+}
+
+// Jobs 返回已经结束的连接中收到的打印数据，只查询状态的连接不包括在内
+func (s *Server) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Job(nil), s.jobs...)
+}
+
+// Queries 返回收到的状态查询指令的数量
+func (s *Server) Queries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries
+}
+
+// WaitJobs 等待至少收到n个打印任务，超时返回错误
+func (s *Server) WaitJobs(n int, timeout time.Duration) ([]Job, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		jobs := s.Jobs()
+		if len(jobs) >= n {
+			return jobs, nil
+		}
+		if time.Now().After(deadline) {
+			return jobs, fmt.Errorf("printertest: got %d jobs, want %d", len(jobs), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Reset 清空收到的打印任务
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = nil
+	s.queries = 0
+}
+
+// Close 停止模拟打印机并关闭所有连接
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// reset 关闭连接并发送RST
+func reset(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	if s.currentBehavior().ResetAfter < 0 {
+		reset(conn)
+		return
+	}
+
+	queryBytes := 0
+	e := raster.NewEscPosEmulator(576)
+	e.Response = conn
+	e.Status = func(cmd []byte) []byte {
+		queryBytes += len(cmd)
+		s.mu.Lock()
+		s.queries++
+		s.mu.Unlock()
+		b := s.currentBehavior()
+		time.Sleep(b.Delay)
+		if b.NoResponse {
+			return nil
+		}
+		return statusResponse(cmd, b.Status)
+	}
+
+	var job Job
+	buf := make([]byte, 1024)
+	for {
+		b := s.currentBehavior()
+		time.Sleep(b.Delay)
+		n, err := conn.Read(buf)
+		if b.ResetAfter > 0 && len(job.Data)+n >= b.ResetAfter {
+			n = b.ResetAfter - len(job.Data)
+			job.Reset = true
+		}
+		job.Data = append(job.Data, buf[:n]...)
+		e.Write(buf[:n])
+		if job.Reset {
+			reset(conn)
+			break
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if len(job.Data) > queryBytes {
+		job.Image = e.Image()
+		s.mu.Lock()
+		s.jobs = append(s.jobs, job)
+		s.mu.Unlock()
+	}
+}
+
+// statusResponse 按状态生成DLE EOT n、GS a n和GS r n的响应
+func statusResponse(cmd []byte, st eprinter.PrinterStatus) []byte {
+	bit := func(set bool, b byte) byte {
+		if set {
+			return b
+		}
+		return 0
+	}
+	n := cmd[2]
+	switch {
+	case cmd[0] == 0x10 && n == 1:
+		return []byte{0x12 | bit(st.DrawerOpen, 0x04) | bit(!st.Online, 0x08)}
+	case cmd[0] == 0x10 && n == 2:
+		hasError := st.MechanicalError || st.AutoCutterError || st.UnrecoverableError || st.AutoRecoverableError
+		return []byte{0x12 | bit(st.CoverOpen, 0x04) | bit(st.PaperFeed, 0x08) | bit(st.PaperEnd, 0x20) | bit(hasError, 0x40)}
+	case cmd[0] == 0x10 && n == 3:
+		return []byte{0x12 | bit(st.MechanicalError, 0x04) | bit(st.AutoCutterError, 0x08) |
+			bit(st.UnrecoverableError, 0x20) | bit(st.AutoRecoverableError, 0x40)}
+	case cmd[0] == 0x10 && n == 4:
+		return []byte{0x12 | bit(st.PaperNearEnd, 0x0C) | bit(st.PaperEnd, 0x60)}
+	case cmd[1] == 'a' && n != 0:
+		return []byte{
+			0x10 | bit(st.DrawerOpen, 0x04) | bit(!st.Online, 0x08) | bit(st.CoverOpen, 0x20) | bit(st.PaperFeed, 0x40),
+			bit(st.MechanicalError, 0x04) | bit(st.AutoCutterError, 0x08) | bit(st.UnrecoverableError, 0x20) | bit(st.AutoRecoverableError, 0x40),
+			bit(st.PaperNearEnd, 0x03) | bit(st.PaperEnd, 0x0C),
+			0x00,
+		}
+	case cmd[1] == 'r' && (n == 1 || n == 49):
+		return []byte{bit(st.PaperNearEnd, 0x03) | bit(st.PaperEnd, 0x0C)}
+	case cmd[1] == 'r':
+		return []byte{bit(st.DrawerOpen, 0x01)}
+	}
+	return nil
+}
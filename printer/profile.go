@@ -0,0 +1,105 @@
+package printer
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// PrinterProfile 打印机设置，每个任务开始时在初始化指令之后发送，不需要在打印机上修改设置。
+// 浓度、速度、代码页、行间距和蜂鸣只用于ESC/POS打印机
+type PrinterProfile struct {
+	Density     *int   `json:"density,omitempty"`      // 打印浓度 -6~6，0为标准浓度（GS ( K 49）
+	Speed       int    `json:"speed,omitempty"`        // 打印速度 1~13，1最慢（GS ( K 50），0为不设置
+	CodePage    *int   `json:"code_page,omitempty"`    // 字符代码页（ESC t）
+	LineSpacing int    `json:"line_spacing,omitempty"` // 默认行间距（点，ESC 3），0为不设置
+	Beep        int    `json:"beep,omitempty"`         // 任务结束时蜂鸣的次数 1~9（ESC B n t，Epson不支持），0为不蜂鸣
+	Init        string `json:"init,omitempty"`         // 任务开始时发送的十六进制指令
+	Trailer     string `json:"trailer,omitempty"`      // 任务结束时发送的十六进制指令
+}
+
+// escposInit 返回任务开始时发送的ESC/POS设置指令，profile为nil时返回nil
+func (pr *PrinterProfile) escposInit() []byte {
+	if pr == nil {
+		return nil
+	}
+	var cmd []byte
+	if pr.Density != nil {
+		cmd = append(cmd, 0x1D, '(', 'K', 2, 0, 49, byte(int8(*pr.Density))) // -6~-1为250~255
+	}
+	if pr.Speed > 0 {
+		cmd = append(cmd, 0x1D, '(', 'K', 2, 0, 50, byte(pr.Speed))
+	}
+	if pr.CodePage != nil {
+		cmd = append(cmd, 0x1B, 't', byte(*pr.CodePage))
+	}
+	cmd = append(cmd, pr.lineSpacingCommand()...)
+	return append(cmd, pr.initCommand()...)
+}
+
+// lineSpacingCommand 返回设置默认行间距的ESC 3指令，没有设置行间距时返回nil
+func (pr *PrinterProfile) lineSpacingCommand() []byte {
+	if pr == nil || pr.LineSpacing <= 0 {
+		return nil
+	}
+	return []byte{0x1B, '3', byte(pr.LineSpacing)}
+}
+
+// escposTrailer 返回任务结束时发送的ESC/POS指令
+func (pr *PrinterProfile) escposTrailer() []byte {
+	if pr == nil {
+		return nil
+	}
+	var cmd []byte
+	if pr.Beep > 0 {
+		// ESC B n t 是很多兼容ESC/POS的打印机的蜂鸣指令，Epson使用ESC ( A，需要时在trailer中配置
+		cmd = append(cmd, 0x1B, 'B', byte(pr.Beep), 3) // 每次蜂鸣150ms
+	}
+	return append(cmd, pr.trailerCommand()...)
+}
+
+// initCommand 返回配置的init指令，十六进制格式错误时忽略
+func (pr *PrinterProfile) initCommand() []byte {
+	if pr == nil {
+		return nil
+	}
+	cmd, _ := hex.DecodeString(pr.Init)
+	return cmd
+}
+
+// trailerCommand 返回配置的trailer指令，十六进制格式错误时忽略
+func (pr *PrinterProfile) trailerCommand() []byte {
+	if pr == nil {
+		return nil
+	}
+	cmd, _ := hex.DecodeString(pr.Trailer)
+	return cmd
+}
+
+// validate 检查设置的范围，Star指令集只支持init和trailer
+func (pr *PrinterProfile) validate(commandSet string) error {
+	if pr == nil {
+		return nil
+	}
+	if commandSet == "star" && (pr.Density != nil || pr.Speed != 0 || pr.CodePage != nil || pr.LineSpacing != 0 || pr.Beep != 0) {
+		return fmt.Errorf("profile: star command set only supports init and trailer")
+	}
+	switch {
+	case pr.Density != nil && (*pr.Density < -6 || *pr.Density > 6):
+		return fmt.Errorf("profile: density %d out of range -6~6", *pr.Density)
+	case pr.Speed < 0 || pr.Speed > 13:
+		return fmt.Errorf("profile: speed %d out of range 1~13", pr.Speed)
+	case pr.CodePage != nil && (*pr.CodePage < 0 || *pr.CodePage > 255):
+		return fmt.Errorf("profile: code page %d out of range 0~255", *pr.CodePage)
+	case pr.LineSpacing < 0 || pr.LineSpacing > 255:
+		return fmt.Errorf("profile: line spacing %d out of range 0~255", pr.LineSpacing)
+	case pr.Beep < 0 || pr.Beep > 9:
+		return fmt.Errorf("profile: beep %d out of range 1~9", pr.Beep)
+	}
+	if _, err := hex.DecodeString(pr.Init); err != nil {
+		return fmt.Errorf("profile: invalid init: %w", err)
+	}
+	if _, err := hex.DecodeString(pr.Trailer); err != nil {
+		return fmt.Errorf("profile: invalid trailer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package printer
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// ReloadPrinters 重新读取配置文件并创建新的Printers。
+// 配置文件无效时返回错误，current保持不变；
+// 配置没有变化的打印机直接复用，保留打印队列和连接；
+// 配置变化或被删除的打印机先停止接收新任务，只等正在打印的任务完成，
+// 磁盘队列中未完成的任务由新的打印机继续打印，其他排队的任务返回 ErrSpoolClosed
+func ReloadPrinters(filename string, current Printers) (Printers, error) {
+	configPrinters, err := ReadConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateConfig(configPrinters); err != nil {
+		return nil, err
+	}
+
+	reused := reusablePrinters(configPrinters, current)
+	// 先关闭引用其他打印机的镜像打印机和打印机组，再关闭它们的成员
+	for _, kind := range []string{"mirror", "group", ""} {
+		for name, printer := range current {
+			spool, ok := printer.(*SpoolPrinter)
+			if !ok || reused[name] != nil || !isKind(spool.config.Type, kind) {
+				continue
+			}
+			fmt.Printf("Printer %s changed or removed, closing\n", name)
+			spool.Handover()
+			<-spool.Done()
+		}
+	}
+
+	printers := buildPrinters(filename, configPrinters, reused)
+	if len(printers) == 0 {
+		return nil, fmt.Errorf("no printers configured or all failed to open")
+	}
+	return printers, nil
+}
+
+// isKind 判断打印机类型，kind为空表示普通打印机
+func isKind(typ, kind string) bool {
+	if kind == "" {
+		return typ != "mirror" && typ != "group"
+	}
+	return typ == kind
+}
+
+// ValidateConfig 检查配置是否有效，热加载时配置有错误则保留旧的配置。
+// 普通打印机通过NewPrinter检查类型，NewPrinter只创建对象不会连接打印机
+func ValidateConfig(configPrinters map[string]ConfigPrinter) error {
+	if len(configPrinters) == 0 {
+		return fmt.Errorf("no printers configured")
+	}
+	for name, config := range configPrinters {
+		if config.Copies < 0 || config.Copies > MaxCopies {
+			return fmt.Errorf("printer %s: copies %d out of range 1~%d", name, config.Copies, MaxCopies)
+		}
+		switch config.Type {
+		case "group", "mirror":
+			if len(config.Members) == 0 {
+				return fmt.Errorf("printer %s: no members", name)
+			}
+			for _, member := range config.Members {
+				memberConfig, ok := configPrinters[member]
+				if !ok {
+					return fmt.Errorf("printer %s: unknown member %s", name, member)
+				}
+				// 打印机组的成员只能是普通打印机，镜像打印机的成员不能是镜像打印机
+				if memberConfig.Type == "mirror" || config.Type == "group" && memberConfig.Type == "group" {
+					return fmt.Errorf("printer %s: member %s can not be a %s", name, member, memberConfig.Type)
+				}
+			}
+		default:
+			if config.Address == "" {
+				return fmt.Errorf("printer %s: missing address", name)
+			}
+			if config.NewPrinter() == nil {
+				return fmt.Errorf("printer %s: unknown type %q", name, config.Type)
+			}
+			if _, ok := CommandSets[config.CommandSet]; config.CommandSet != "" && !ok {
+				return fmt.Errorf("printer %s: unknown command set %q", name, config.CommandSet)
+			}
+			if _, ok := RasterModes[config.RasterMode]; config.RasterMode != "" && !ok {
+				return fmt.Errorf("printer %s: unknown raster mode %q", name, config.RasterMode)
+			}
+			if _, ok := FitModes[config.Fit]; config.Fit != "" && !ok {
+				return fmt.Errorf("printer %s: unknown fit %q", name, config.Fit)
+			}
+			switch config.Resample {
+			case "", raster.ResampleArea, raster.ResampleNearest:
+			default:
+				return fmt.Errorf("printer %s: unknown resample %q", name, config.Resample)
+			}
+			if err := config.Profile.validate(config.CommandSet); err != nil {
+				return fmt.Errorf("printer %s: %w", name, err)
+			}
+			switch config.Completion {
+			case "", CompletionSleep:
+			case CompletionReceived, CompletionPrinted:
+				if config.CommandSet == "star" {
+					return fmt.Errorf("printer %s: completion %q needs escpos command set", name, config.Completion)
+				}
+			default:
+				return fmt.Errorf("printer %s: unknown completion %q", name, config.Completion)
+			}
+			if config.Type == "usb" {
+				if _, _, err := parseUSBAddress(config.Address); err != nil {
+					return fmt.Errorf("printer %s: %w", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// reusablePrinters 返回配置没有变化的打印机，打印机组和镜像打印机还要求所有成员都没有变化
+func reusablePrinters(configPrinters map[string]ConfigPrinter, current Printers) Printers {
+	reused := make(Printers)
+	for _, kind := range []string{"", "group", "mirror"} {
+		for name, config := range configPrinters {
+			if !isKind(config.Type, kind) {
+				continue
+			}
+			spool, ok := current[name].(*SpoolPrinter)
+			if !ok || !reflect.DeepEqual(spool.config, config) {
+				continue
+			}
+			membersReused := true
+			for _, member := range config.Members {
+				if reused[member] == nil {
+					membersReused = false
+				}
+			}
+			if membersReused {
+				reused[name] = spool
+			}
+		}
+	}
+	return reused
+}
@@ -0,0 +1,31 @@
+package printer
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// setFlowControl 为串口设备开启RTS/CTS硬件流控（rtscts）或XON/XOFF软件流控（xonxoff）。
+// 串口库没有提供流控设置，串口参数保存在设备上，打开同一设备修改后对已打开的串口同样有效。
+// 软件流控由内核处理：收到XOFF后暂停发送直到收到XON，流控字符不会出现在读取的数据中
+func setFlowControl(name, flow string) error {
+	f, err := os.OpenFile(name, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fd := int(f.Fd())
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	switch flow {
+	case "rtscts":
+		t.Cflag |= unix.CRTSCTS
+	case "xonxoff":
+		t.Iflag |= unix.IXON
+		t.Iflag &^= unix.IXANY // 只有XON恢复发送
+	}
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
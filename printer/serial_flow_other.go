@@ -0,0 +1,10 @@
+//go:build !linux
+
+package printer
+
+import "fmt"
+
+// setFlowControl 串口库在其他系统上没有提供流控设置，USB虚拟串口不需要流控
+func setFlowControl(name, flow string) error {
+	return fmt.Errorf("flow=%s is only supported on linux", flow)
+}
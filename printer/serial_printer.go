@@ -21,27 +21,31 @@ type SerialPrinter struct {
 	serialConfig      string                      // 串口配置字符串
 	fd                *serial.Port                // 打印机文件描述符
 	transformer       transformer.TransformerFunc // 用于转换图像的转换器
+	twoColor          bool                        // 是否支持双色打印
+	commands          CommandSet                  // 指令集
+	fit               fitFunc                     // 调整图像到纸张宽度，见FitModes
+	completion        string                      // 确认打印完成的方式
+	baud              int                         // 打开串口时解析的波特率
+	bannerWidth       int                         // 多份打印时每份前面 "COPY n/N" 标题的宽度，0为不打印
 }
 
 func (p *SerialPrinter) String() string {
 	return fmt.Sprintf("SerialPrinter{serialConfig: %s, paperWidth: %d, marginBottom: %d}", p.serialConfig, p.paperWidth, p.marginBottom)
 }
 
-// serialConfig格式: "COM1,baud=115200,databits=8,parity=N,stopbits=1"
-func (p *SerialPrinter) Open() error {
-	if p.serialConfig == "" {
-		return os.ErrInvalid
-	}
+func (p *SerialPrinter) PaperWidth() int {
+	return p.paperWidth
+}
+
+// parseSerialConfig 解析串口配置字符串，flow为none（默认）、rtscts或xonxoff
+func parseSerialConfig(config string) (c serial.Config, flow string) {
 	// 默认参数（适配大多数80mm热敏USB虚拟串口打印机）
-	port := "COM1"
-	baud := 115200
-	databits := 8
-	parity := serial.ParityNone
-	stopbits := serial.Stop1
+	c = serial.Config{Name: "COM1", Baud: 115200, Size: 8, Parity: serial.ParityNone, StopBits: serial.Stop1}
+	flow = "none"
 
-	parts := strings.Split(p.serialConfig, ",")
+	parts := strings.Split(config, ",")
 	if len(parts) > 0 {
-		port = parts[0]
+		c.Name = parts[0]
 	}
 	for _, part := range parts[1:] {
 		kv := strings.SplitN(part, "=", 2)
@@ -52,58 +56,115 @@ func (p *SerialPrinter) Open() error {
 		switch key {
 		case "baud":
 			if v, err := strconv.Atoi(val); err == nil {
-				baud = v
+				c.Baud = v
 			}
 		case "databits":
 			if v, err := strconv.Atoi(val); err == nil {
-				databits = v
+				c.Size = byte(v)
 			}
 		case "parity":
 			switch strings.ToUpper(val) {
 			case "N":
-				parity = serial.ParityNone
+				c.Parity = serial.ParityNone
 			case "O":
-				parity = serial.ParityOdd
+				c.Parity = serial.ParityOdd
 			case "E":
-				parity = serial.ParityEven
+				c.Parity = serial.ParityEven
 			}
 		case "stopbits":
 			if val == "2" {
-				stopbits = serial.Stop2
+				c.StopBits = serial.Stop2
+			}
+		case "flow":
+			switch strings.ToLower(val) {
+			case "rtscts", "xonxoff":
+				flow = strings.ToLower(val)
 			}
 		}
 	}
+	return c, flow
+}
+
+// serialConfig格式: "COM1,baud=115200,databits=8,parity=N,stopbits=1,flow=rtscts"
+// flow可以是none（默认）、rtscts（硬件流控）或xonxoff（软件流控），流控仅支持Linux
+func (p *SerialPrinter) Open() error {
+	// 查询状态时读取超时
+	return p.open(statusTimeout)
+}
 
-	c := &serial.Config{
-		Name:     port,
-		Baud:     baud,
-		Size:     byte(databits),
-		Parity:   parity,
-		StopBits: stopbits,
+// open 打开串口，readTimeout为每次读取没有数据时等待的时间
+func (p *SerialPrinter) open(readTimeout time.Duration) error {
+	if p.serialConfig == "" {
+		return os.ErrInvalid
 	}
-	s, err := serial.OpenPort(c)
+	c, flow := parseSerialConfig(p.serialConfig)
+	c.ReadTimeout = readTimeout
+	s, err := serial.OpenPort(&c)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrPrinterOffline, err)
+	}
+	if flow != "none" {
+		if err := setFlowControl(c.Name, flow); err != nil {
+			s.Close()
+			return fmt.Errorf("failed to enable %s flow control: %w", flow, err)
+		}
 	}
 	p.fd = s
+	p.baud = c.Baud
+	return nil
+}
+
+// serialPort 供waitCompletion读取响应的串口。串口的读取超时在打开时设置（打印时为100ms），
+// 没有数据时Read很快返回，waitCompletion每次读取后检查截止时间，所以SetReadDeadline不需要做任何事
+type serialPort struct {
+	*serial.Port
+}
+
+func (serialPort) SetReadDeadline(t time.Time) error {
 	return nil
 }
 
+// Status 通过DLE EOT和GS a查询打印机实时状态
+func (p *SerialPrinter) Status() (PrinterStatus, error) {
+	if !p.commands.RealTimeStatus() {
+		return PrinterStatus{}, ErrStatusUnsupported
+	}
+	if err := p.Open(); err != nil {
+		return PrinterStatus{}, err
+	}
+	defer p.fd.Close()
+	return queryStatus(p.fd)
+}
+
 func (p *SerialPrinter) OpenCashBox() error {
 	err := p.Reset()
 	if err != nil {
 		return fmt.Errorf("failed to reset printer: %w", err)
 	}
 	defer p.fd.Close()
-	p.fd.Write(p.cashDrawerCommand)
+	if _, err := p.fd.Write(p.cashDrawerCommand); err != nil {
+		return fmt.Errorf("failed to write data to printer: %w", err)
+	}
 	return nil
 }
 
 func (p *SerialPrinter) PrintRasterImage(img *raster.RasterImage) error {
+	return p.PrintRasterCopies(img, 1)
+}
+
+// PrintRasterCopies 打印copies份图像，每页的打印数据只生成一次，每份之间切纸
+func (p *SerialPrinter) PrintRasterCopies(img *raster.RasterImage, copies int) error {
 	img = p.transformer(img) // 使用转换器转换图像
 	if img == nil {
 		return nil // 如果转换器返回 nil，表示不需要打印图像
 	}
+	var pages [][]byte
+	for _, page := range img.CutPages() {
+		page = p.fit(page) // 比纸张宽的图像按配置裁剪或缩放
+		page.AutoMarginLeft(p.paperWidth)
+		page.AddMarginBottom(p.marginBottom)
+		pages = append(pages, append(p.commands.Raster(page, p.twoColor), p.cutCommand...)) // 切纸命令
+	}
 
 	err := p.Reset()
 	if err != nil {
@@ -111,20 +172,31 @@ func (p *SerialPrinter) PrintRasterImage(img *raster.RasterImage) error {
 	}
 	defer p.fd.Close()
 
-	for _, page := range img.CutPages() {
-		page.AutoMarginLeft(p.paperWidth)
-		page.AddMarginBottom(p.marginBottom)
-		p.fd.Write(page.ToEscPosRasterCommand(1024))
-		p.fd.Write(p.cutCommand)    // 切纸命令
-		time.Sleep(1 * time.Second) // 等待打印机处理
+	sent := 0
+	for _, data := range repeatCopies(pages, copies, p.commands, p.bannerWidth) {
+		if _, err := p.fd.Write(data); err != nil {
+			return fmt.Errorf("failed to write data to printer: %w", err)
+		}
+		sent += len(data)
+		if p.completion == "" || p.completion == CompletionSleep {
+			time.Sleep(sleepPerPage) // 等待打印机处理
+		}
+	}
+	trailer := p.commands.Trailer()
+	if _, err := p.fd.Write(trailer); err != nil {
+		return fmt.Errorf("failed to write data to printer: %w", err)
 	}
+	sent += len(trailer)
 
-	return nil
+	return waitCompletion(serialPort{p.fd}, p.completion, transferTimeout(sent, p.baud))
 }
 
+// Reset 打开串口并初始化打印机，打印时读取超时较短，关闭串口时不需要等待读取结束
 func (p *SerialPrinter) Reset() error {
-	p.Open()
-	_, err := p.fd.Write([]byte{0x1B, 0x40}) // 初始化打印机
+	if err := p.open(100 * time.Millisecond); err != nil {
+		return err
+	}
+	_, err := p.fd.Write(p.commands.Init()) // 初始化打印机
 	if err != nil {
 		p.fd.Close()
 		p.fd = nil
@@ -133,18 +205,26 @@ func (p *SerialPrinter) Reset() error {
 	return nil
 }
 
+// PrintEscPosCopies 打印ePOS文档转换的ESC/POS指令，前后加上打印机设置
+func (p *SerialPrinter) PrintEscPosCopies(data []byte, copies int) error {
+	return p.PrintRaw(escposCopies(p.commands, data, copies))
+}
+
 func (p *SerialPrinter) PrintRaw(data []byte) error {
-	err := p.Open()
+	if len(data) == 0 {
+		return fmt.Errorf("no data to print")
+	}
+	err := p.open(100 * time.Millisecond)
 	if err != nil {
 		return fmt.Errorf("failed to open printer: %w", err)
 	}
 	defer p.fd.Close()
-	if len(data) == 0 {
-		return fmt.Errorf("no data to print")
-	}
 	if _, err := p.fd.Write(data); err != nil {
 		return fmt.Errorf("failed to write data to printer: %w", err)
 	}
-	time.Sleep(1 * time.Second) // 等待打印机处理
-	return nil
+	if p.completion == "" || p.completion == CompletionSleep {
+		time.Sleep(sleepPerPage) // 等待打印机处理
+		return nil
+	}
+	return waitCompletion(serialPort{p.fd}, p.completion, transferTimeout(len(data), p.baud))
 }
@@ -0,0 +1,749 @@
+package printer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// 打印任务类型
+type JobKind string
+
+const (
+	JobRaster JobKind = "raster" // 光栅图像
+	JobRaw    JobKind = "raw"    // 原始指令
+	JobPulse  JobKind = "pulse"  // 打开钱箱
+)
+
+// 打印任务状态
+type JobState string
+
+const (
+	JobQueued   JobState = "queued"   // 排队中
+	JobPrinting JobState = "printing" // 打印中
+	JobRetrying JobState = "retrying" // 打印机离线，等待重试
+	JobDone     JobState = "done"     // 打印成功
+	JobFailed   JobState = "failed"   // 打印失败
+)
+
+var (
+	ErrQueueFull   = errors.New("print queue is full")
+	ErrJobNotFound = errors.New("print job not found")
+	ErrSpoolClosed = errors.New("print spooler is closed")
+	ErrJobDeferred = errors.New("printer unavailable, job spooled for retry")
+	ErrJobExpired  = errors.New("print job expired")
+	ErrJobPanic    = errors.New("print job crashed") // 打印时发生panic，任务不重试
+)
+
+const (
+	defaultQueueSize  = 32               // 默认队列长度
+	defaultJobHistory = 100              // 每台打印机保留的已完成任务数量
+	defaultMaxAge     = time.Hour        // 离线任务默认保留时间
+	retryMinBackoff   = 2 * time.Second  // 第一次重试的等待时间
+	retryMaxBackoff   = 60 * time.Second // 重试等待时间的上限
+	defaultWaitTime   = 60 * time.Second // 同步打印时等待结果的默认时间
+)
+
+var jobSeq atomic.Uint64
+
+// Job 表示一个打印任务
+type Job struct {
+	ID         string              `json:"id"`
+	Printer    string              `json:"printer"`
+	Kind       JobKind             `json:"kind"`
+	Source     string              `json:"source,omitempty"` // 请求来源，记录在打印历史中
+	State      JobState            `json:"state"`
+	Error      string              `json:"error,omitempty"`
+	CreatedAt  time.Time           `json:"created_at"`
+	FinishedAt time.Time           `json:"finished_at,omitzero"`
+	Attempts   int                 `json:"attempts,omitempty"` // 失败后已重试的次数
+	Copies     int                 `json:"copies,omitempty"`   // 打印份数，0为打印机配置的份数
+	Image      *raster.RasterImage `json:"-"`                  // JobRaster的图像
+	Data       []byte              `json:"-"`                  // JobRaw的数据
+	EscPos     bool                `json:"escpos,omitempty"`   // JobRaw的数据是ePOS文档转换的ESC/POS指令，打印时加上打印机设置
+	err        error               // 打印结果
+	done       chan struct{}       // 任务完成时关闭
+	deferred   chan struct{}       // 任务转为后台重试时关闭
+	deferErr   error               // 转为后台重试时的错误
+	isDeferred bool                // deferred是否已关闭
+	noRetry    bool                // 失败时立即返回，不保存到磁盘也不重试
+	member     bool                // 打印机组或镜像打印机的成员任务，打印历史由打印机组或镜像打印机记录
+}
+
+// NewRasterJob 创建一个光栅图像打印任务
+func NewRasterJob(img *raster.RasterImage) *Job {
+	return &Job{Kind: JobRaster, Image: img}
+}
+
+// NewRawJob 创建一个原始指令打印任务
+func NewRawJob(data []byte) *Job {
+	return &Job{Kind: JobRaw, Data: data}
+}
+
+// NewEscPosJob 创建一个ePOS文档转换的ESC/POS指令打印任务，与原始指令不同，打印时加上打印机设置
+func NewEscPosJob(data []byte) *Job {
+	return &Job{Kind: JobRaw, Data: data, EscPos: true}
+}
+
+// NewPulseJob 创建一个打开钱箱的任务
+func NewPulseJob() *Job {
+	return &Job{Kind: JobPulse}
+}
+
+// SpoolPrinter 为打印机提供有界的先进先出队列，每台打印机由一个goroutine依次执行任务，
+// 避免多个请求同时访问同一台打印机导致数据交错。
+// 启用磁盘队列后，未完成的任务保存在磁盘上，打印机离线时按退避时间重试，服务重启后继续打印
+type SpoolPrinter struct {
+	name     string
+	printer  EPrinter
+	queue    chan *Job
+	mu       sync.Mutex
+	jobs     map[string]*Job // 排队中和最近完成的任务
+	history  []string        // 已完成任务的ID，按完成顺序
+	closing  bool            // 是否已停止接收新任务
+	handover bool            // Handover之后不再打印队列中剩余的任务
+	closed   chan struct{}   // Close时关闭，通知工作goroutine退出
+	stopped  chan struct{}   // 工作goroutine退出时关闭
+	device   sync.Mutex      // 打印和查询状态不能同时访问打印机
+	store    *spoolStore     // 磁盘队列，为nil时不持久化也不重试
+	maxAge   time.Duration   // 任务从创建起的最长保留时间，超过后不再重试
+	config   ConfigPrinter   // 创建打印机时的配置
+	retry    error           // 正在重试的任务最近一次的错误，为nil时打印机没有在重试
+}
+
+// NewSpoolPrinter 创建打印队列并启动工作goroutine
+func NewSpoolPrinter(name string, printer EPrinter, queueSize int) *SpoolPrinter {
+	s := newSpoolPrinter(name, printer, queueSize, 0)
+	go s.worker()
+	return s
+}
+
+// NewDurableSpoolPrinter 创建保存在dir目录下的磁盘打印队列，
+// 先加载上次未完成的任务，再启动工作goroutine
+func NewDurableSpoolPrinter(name string, printer EPrinter, queueSize int, dir string, maxAge time.Duration) (*SpoolPrinter, error) {
+	store, err := newSpoolStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	var pending []*Job
+	for _, job := range store.load() {
+		if time.Since(job.CreatedAt) > maxAge {
+			fmt.Printf("Spooled job %s for printer %s expired, discarded\n", job.ID, name)
+			store.remove(job.ID)
+			continue
+		}
+		pending = append(pending, job)
+	}
+
+	s := newSpoolPrinter(name, printer, queueSize, len(pending))
+	s.store = store
+	s.maxAge = maxAge
+	for _, job := range pending {
+		job.Printer = name
+		job.State = JobQueued
+		job.done = make(chan struct{})
+		job.deferred = make(chan struct{})
+		s.jobs[job.ID] = job
+		s.queue <- job
+	}
+	if len(pending) > 0 {
+		fmt.Printf("Loaded %d spooled jobs for printer %s\n", len(pending), name)
+	}
+	go s.worker()
+	return s, nil
+}
+
+// newSpoolPrinter 创建打印队列，extra为队列额外预留的容量（用于加载磁盘上的任务）
+func newSpoolPrinter(name string, printer EPrinter, queueSize int, extra int) *SpoolPrinter {
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	return &SpoolPrinter{
+		name:    name,
+		printer: printer,
+		queue:   make(chan *Job, queueSize+extra),
+		jobs:    make(map[string]*Job),
+		closed:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (s *SpoolPrinter) String() string {
+	return fmt.Sprintf("%v (queue %d/%d)", s.printer, len(s.queue), cap(s.queue))
+}
+
+// Printer 返回被包装的打印机
+func (s *SpoolPrinter) Printer() EPrinter {
+	return s.printer
+}
+
+// Config 返回创建打印机时的配置
+func (s *SpoolPrinter) Config() ConfigPrinter {
+	return s.config
+}
+
+func (s *SpoolPrinter) PaperWidth() int {
+	return PaperWidth(s.printer)
+}
+
+// Submit 将任务加入队列并立即返回任务ID，队列已满时返回 ErrQueueFull
+func (s *SpoolPrinter) Submit(job *Job) (string, error) {
+	// ID以毫秒时间开头，服务重启后序号从头开始也不会与打印历史中的ID重复
+	now := strings.Replace(time.Now().Format("20060102150405.000"), ".", "", 1)
+	job.ID = fmt.Sprintf("%s-%d", now, jobSeq.Add(1))
+	job.Printer = s.name
+	job.State = JobQueued
+	job.CreatedAt = time.Now()
+	if job.Copies <= 0 {
+		job.Copies = s.config.Copies // 请求没有指定份数时使用打印机配置的份数
+	}
+	job.done = make(chan struct{})
+	job.deferred = make(chan struct{})
+
+	// 在锁内入队，保证Close之后不会再有任务进入队列
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return "", ErrSpoolClosed
+	}
+	if s.retry != nil && !job.durable() {
+		// 打印机正在重试前面的任务，钱箱和打印机组成员的任务排在后面等待没有意义
+		return "", fmt.Errorf("%w: %w", ErrPrinterOffline, s.retry)
+	}
+	if len(s.queue) == cap(s.queue) {
+		return "", ErrQueueFull
+	}
+	if s.store != nil {
+		// 保存失败时仍然打印，只是无法在重启后恢复
+		if err := s.store.save(job); err != nil {
+			fmt.Printf("Failed to spool job %s to disk: %v\n", job.ID, err)
+		}
+	}
+	// 只有本函数在锁内发送，检查过容量后不会阻塞
+	s.queue <- job
+	s.jobs[job.ID] = job
+	if s.retry != nil {
+		job.deferNow(s.retry) // 排在重试的任务后面，立即转为后台打印
+	}
+	return job.ID, nil
+}
+
+// Reprint 使用已有任务的数据重新提交一个任务，返回新任务的ID
+func (s *SpoolPrinter) Reprint(id string) (string, error) {
+	s.mu.Lock()
+	old, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return "", ErrJobNotFound
+	}
+	return s.Submit(&Job{Kind: old.Kind, Image: old.Image, Data: old.Data, EscPos: old.EscPos, Copies: old.Copies})
+}
+
+// Job 返回任务当前状态的快照
+func (s *SpoolPrinter) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.snapshot(), true
+}
+
+// Jobs 返回排队中和最近完成的任务，按创建时间排序
+func (s *SpoolPrinter) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job.snapshot())
+	}
+	sortJobs(jobs)
+	return jobs
+}
+
+// Wait 等待任务完成并返回打印结果
+func (s *SpoolPrinter) Wait(ctx context.Context, id string) (Job, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	select {
+	case <-job.done:
+	case <-ctx.Done():
+		return s.snapshot(job), ctx.Err()
+	}
+	return s.snapshot(job), job.err
+}
+
+// Close 停止接收新任务，已在队列中的任务继续打印，
+// 启用磁盘队列时打印机离线的任务留在磁盘上，下次启动时继续打印
+func (s *SpoolPrinter) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closing {
+		s.closing = true
+		close(s.closed)
+	}
+}
+
+// Handover 与Close相同，但只完成正在打印的任务，不等待打印机处理队列中剩余的任务：
+// 磁盘上的任务留给使用同一目录的新打印机继续打印，其他任务返回 ErrSpoolClosed。
+// 热加载配置时使用，离线打印机的队列不需要逐个等待连接超时
+func (s *SpoolPrinter) Handover() {
+	s.mu.Lock()
+	s.handover = true
+	s.mu.Unlock()
+	s.Close()
+}
+
+// Status 查询打印机状态，正在打印时等待当前任务完成，重试等待期间可以查询
+func (s *SpoolPrinter) Status() (PrinterStatus, error) {
+	s.device.Lock()
+	defer s.device.Unlock()
+	return Status(s.printer)
+}
+
+// Print 提交任务并等待打印结果，与PrintRasterImage等方法相同，但可以设置任务的来源和等待时间
+func (s *SpoolPrinter) Print(ctx context.Context, job *Job) error {
+	return s.submitAndWaitContext(ctx, job)
+}
+
+func (s *SpoolPrinter) OpenCashBox() error {
+	return s.submitAndWait(NewPulseJob())
+}
+
+func (s *SpoolPrinter) PrintRasterImage(img *raster.RasterImage) error {
+	return s.submitAndWait(NewRasterJob(img))
+}
+
+func (s *SpoolPrinter) PrintRaw(data []byte) error {
+	return s.submitAndWait(NewRawJob(data))
+}
+
+func (s *SpoolPrinter) PrintRasterCopies(img *raster.RasterImage, copies int) error {
+	job := NewRasterJob(img)
+	job.Copies = copies
+	return s.submitAndWait(job)
+}
+
+func (s *SpoolPrinter) PrintRawCopies(data []byte, copies int) error {
+	job := NewRawJob(data)
+	job.Copies = copies
+	return s.submitAndWait(job)
+}
+
+func (s *SpoolPrinter) PrintEscPosCopies(data []byte, copies int) error {
+	job := NewEscPosJob(data)
+	job.Copies = copies
+	return s.submitAndWait(job)
+}
+
+// submitAndWait 提交任务并等待打印结果，
+// 任务因打印机离线转为后台重试时返回 ErrJobDeferred，不再等待，最多等待defaultWaitTime
+func (s *SpoolPrinter) submitAndWait(job *Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultWaitTime)
+	defer cancel()
+	return s.submitAndWaitContext(ctx, job)
+}
+
+// submitAndWaitContext 与submitAndWait相同，ctx结束时不再等待：
+// 保存在磁盘上的任务继续在后台打印，返回 ErrJobDeferred；还没有开始执行的其他任务被取消
+func (s *SpoolPrinter) submitAndWaitContext(ctx context.Context, job *Job) error {
+	if _, err := s.Submit(job); err != nil {
+		return err
+	}
+	select {
+	case <-job.done:
+		return job.err
+	case <-job.deferred:
+		return job.deferErr
+	case <-ctx.Done():
+	}
+	if job.durable() && s.store != nil {
+		return fmt.Errorf("%w: %w", ErrJobDeferred, ctx.Err())
+	}
+	if s.cancel(job, fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())) {
+		return job.err
+	}
+	// 任务已经开始执行，等待结果
+	select {
+	case <-job.done:
+		return job.err
+	case <-job.deferred:
+		return job.deferErr
+	}
+}
+
+// cancel 取消还在排队的任务，任务已经开始执行时返回false
+func (s *SpoolPrinter) cancel(job *Job, err error) bool {
+	s.mu.Lock()
+	queued := job.State == JobQueued
+	if queued {
+		job.State = JobFailed // 工作goroutine不会再执行
+	}
+	s.mu.Unlock()
+	if queued {
+		s.finish(job, err)
+	}
+	return queued
+}
+
+// NoRetry 返回不重试的打印机，打印失败时立即返回错误，
+// 打印机组使用它在成员之间切换，避免任务留在离线成员的队列中稍后重复打印
+func (s *SpoolPrinter) NoRetry() EPrinter {
+	return memberPrinter{spool: s, noRetry: true}
+}
+
+// Member 返回作为镜像打印机成员使用的打印机，任务照常重试，
+// 但不记录打印历史，由镜像打印机记录一次
+func (s *SpoolPrinter) Member() EPrinter {
+	return memberPrinter{spool: s}
+}
+
+// memberPrinter 通过打印队列打印打印机组或镜像打印机的成员任务，
+// 任务不记录打印历史，noRetry时失败也不保存到磁盘或重试
+type memberPrinter struct {
+	spool   *SpoolPrinter
+	noRetry bool
+}
+
+// submit 标记成员任务并等待打印结果
+func (p memberPrinter) submit(job *Job) error {
+	job.member = true
+	job.noRetry = p.noRetry
+	return p.spool.submitAndWait(job)
+}
+
+func (p memberPrinter) String() string {
+	return p.spool.String()
+}
+
+func (p memberPrinter) PaperWidth() int {
+	return p.spool.PaperWidth()
+}
+
+func (p memberPrinter) Status() (PrinterStatus, error) {
+	return p.spool.Status()
+}
+
+func (p memberPrinter) OpenCashBox() error {
+	return p.submit(NewPulseJob())
+}
+
+func (p memberPrinter) PrintRasterImage(img *raster.RasterImage) error {
+	return p.submit(NewRasterJob(img))
+}
+
+func (p memberPrinter) PrintRaw(data []byte) error {
+	return p.submit(NewRawJob(data))
+}
+
+func (p memberPrinter) PrintRasterCopies(img *raster.RasterImage, copies int) error {
+	job := NewRasterJob(img)
+	job.Copies = copies
+	return p.submit(job)
+}
+
+func (p memberPrinter) PrintRawCopies(data []byte, copies int) error {
+	job := NewRawJob(data)
+	job.Copies = copies
+	return p.submit(job)
+}
+
+func (p memberPrinter) PrintEscPosCopies(data []byte, copies int) error {
+	job := NewEscPosJob(data)
+	job.Copies = copies
+	return p.submit(job)
+}
+
+// Done 返回一个channel，Close之后队列中的任务处理完毕时关闭
+func (s *SpoolPrinter) Done() <-chan struct{} {
+	return s.stopped
+}
+
+func (s *SpoolPrinter) worker() {
+	defer close(s.stopped)
+	defer s.disconnect()
+	for {
+		select {
+		case job := <-s.queue:
+			s.run(job)
+		case <-s.closed:
+			// 打印完剩余的任务后退出
+			for {
+				select {
+				case job := <-s.queue:
+					s.run(job)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// run 执行任务，启用磁盘队列时遇到可恢复的错误会按退避时间一直重试，
+// 直到打印成功、任务过期或队列关闭，重试期间后面的任务继续排队以保证打印顺序
+func (s *SpoolPrinter) run(job *Job) {
+	s.mu.Lock()
+	if job.State != JobQueued {
+		// 排队时已被取消
+		s.mu.Unlock()
+		return
+	}
+	if s.handover {
+		s.mu.Unlock()
+		s.handOver(job)
+		return
+	}
+	job.State = JobPrinting
+	s.mu.Unlock()
+	defer s.endRetry()
+
+	backoff := retryMinBackoff
+	for {
+		s.mu.Lock()
+		job.State = JobPrinting
+		s.mu.Unlock()
+
+		err := s.printSafely(job)
+		if err == nil || s.store == nil || !IsRetryable(err) || !job.durable() {
+			s.finish(job, err)
+			return
+		}
+		if time.Since(job.CreatedAt) > s.maxAge {
+			s.finish(job, fmt.Errorf("%w: %w", ErrJobExpired, err))
+			return
+		}
+
+		s.startRetry(job, err)
+		fmt.Printf("Printer %s unavailable, retry job %s in %v: %v\n", s.name, job.ID, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-s.closed:
+			// 任务留在磁盘上，下次启动时继续打印，不能让调用方重新提交
+			s.mu.Lock()
+			job.State = JobQueued
+			job.err = fmt.Errorf("%w: %w", ErrJobDeferred, ErrSpoolClosed)
+			s.mu.Unlock()
+			close(job.done)
+			return
+		}
+		backoff = min(backoff*2, retryMaxBackoff)
+	}
+}
+
+// handOver 不打印Handover之后剩余的任务，磁盘上的任务由新的打印机继续打印
+func (s *SpoolPrinter) handOver(job *Job) {
+	if s.store == nil || !job.durable() {
+		s.finish(job, ErrSpoolClosed)
+		return
+	}
+	s.mu.Lock()
+	job.err = fmt.Errorf("%w: %w", ErrJobDeferred, ErrSpoolClosed)
+	s.mu.Unlock()
+	close(job.done)
+}
+
+// printSafely 在打印机上执行任务，打印机、转换器或光栅处理中的panic转换为错误，只有这个任务失败，
+// 任务从磁盘队列中删除，不会在每次启动时重新加载后再次崩溃
+func (s *SpoolPrinter) printSafely(job *Job) (err error) {
+	s.device.Lock()
+	defer s.device.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Job %s on printer %s crashed: %v\n%s", job.ID, s.name, r, debug.Stack())
+			err = fmt.Errorf("%w: %v", ErrJobPanic, r)
+		}
+	}()
+	return printJob(s.printer, job)
+}
+
+// startRetry 任务转为后台重试，排在后面的任务不再等待：
+// 保存在磁盘上的任务立即转为后台打印，其他任务（打开钱箱、打印机组成员的任务）立即失败
+func (s *SpoolPrinter) startRetry(job *Job, err error) {
+	var failed []*Job
+	s.mu.Lock()
+	job.State = JobRetrying
+	job.Error = err.Error()
+	job.Attempts++
+	job.deferNow(err)
+	s.retry = err
+	for _, queued := range s.jobs {
+		if queued.State != JobQueued {
+			continue
+		}
+		if queued.durable() {
+			queued.deferNow(err)
+		} else {
+			queued.State = JobFailed // 工作goroutine不会再执行
+			failed = append(failed, queued)
+		}
+	}
+	s.mu.Unlock()
+	for _, queued := range failed {
+		s.finish(queued, fmt.Errorf("%w: %w", ErrPrinterOffline, err))
+	}
+}
+
+// endRetry 任务完成后恢复正常排队
+func (s *SpoolPrinter) endRetry() {
+	s.mu.Lock()
+	s.retry = nil
+	s.mu.Unlock()
+}
+
+// deferNow 关闭deferred，通知等待的调用方任务已转为后台打印，调用前需要持有锁
+func (job *Job) deferNow(err error) {
+	if job.isDeferred {
+		return
+	}
+	job.isDeferred = true
+	job.deferErr = fmt.Errorf("%w: %w", ErrJobDeferred, err)
+	close(job.deferred)
+}
+
+// disconnect 队列关闭后断开打印机的持久连接
+func (s *SpoolPrinter) disconnect() {
+	if p, ok := s.printer.(interface{ Disconnect() }); ok {
+		p.Disconnect()
+	}
+}
+
+// durable 判断任务是否保存到磁盘并在失败后重试，打开钱箱的任务过后再执行没有意义，打印机组的成员任务由打印机组重试
+func (job *Job) durable() bool {
+	return !job.noRetry && (job.Kind == JobRaster || job.Kind == JobRaw)
+}
+
+// finish 记录任务结果并从磁盘队列中删除
+func (s *SpoolPrinter) finish(job *Job, err error) {
+	if s.store != nil {
+		s.store.remove(job.ID)
+	}
+
+	s.mu.Lock()
+	job.err = err
+	job.FinishedAt = time.Now()
+	if err != nil {
+		job.State = JobFailed
+		job.Error = err.Error()
+	} else {
+		job.State = JobDone
+		job.Error = ""
+	}
+	s.history = append(s.history, job.ID)
+	for len(s.history) > defaultJobHistory {
+		delete(s.jobs, s.history[0])
+		s.history = s.history[1:]
+	}
+	s.mu.Unlock()
+
+	// 成员任务由打印机组或镜像打印机记录，保存图片较慢，不阻塞等待打印结果的请求。
+	// 预览使用实际的纸张宽度，打印机组和镜像打印机的配置中没有纸张宽度
+	if h := jobHistory.Load(); h != nil && !job.member {
+		config := s.config
+		config.PaperWidth = s.PaperWidth()
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Printf("Failed to record job %s in history: %v\n", job.ID, r)
+				}
+			}()
+			h.Record(job, config)
+		}()
+	}
+	close(job.done)
+}
+
+// printJob 在打印机上执行任务
+func printJob(p EPrinter, job *Job) error {
+	switch job.Kind {
+	case JobRaster:
+		// 打印机会修改图像，失败重试时需要使用原始图像
+		return printRasterCopies(p, job.Image.Clone(), job.Copies)
+	case JobRaw:
+		if job.EscPos {
+			return printEscPosCopies(p, job.Data, job.Copies)
+		}
+		return printRawCopies(p, job.Data, job.Copies)
+	case JobPulse:
+		return p.OpenCashBox()
+	default:
+		return fmt.Errorf("unknown job kind: %s", job.Kind)
+	}
+}
+
+func (s *SpoolPrinter) snapshot(job *Job) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return job.snapshot()
+}
+
+// snapshot 复制任务的公开字段，调用前需要持有锁
+func (job *Job) snapshot() Job {
+	return Job{
+		ID:         job.ID,
+		Printer:    job.Printer,
+		Kind:       job.Kind,
+		Source:     job.Source,
+		State:      job.State,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: job.FinishedAt,
+		Attempts:   job.Attempts,
+		Copies:     job.Copies,
+		Image:      job.Image,
+		Data:       job.Data,
+		EscPos:     job.EscPos,
+	}
+}
+
+func sortJobs(jobs []Job) {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+}
+
+// PrintJob 在打印机上执行任务，打印机有打印队列时通过队列打印，最多等待defaultWaitTime
+func PrintJob(p EPrinter, job *Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultWaitTime)
+	defer cancel()
+	return PrintJobContext(ctx, p, job)
+}
+
+// PrintJobContext 与PrintJob相同，ctx结束时不再等待打印结果，见submitAndWaitContext
+func PrintJobContext(ctx context.Context, p EPrinter, job *Job) error {
+	if spool, ok := p.(*SpoolPrinter); ok {
+		return spool.Print(ctx, job)
+	}
+	return printJob(p, job)
+}
+
+// FindJob 在所有打印队列中查找任务
+func FindJob(printers Printers, id string) (*SpoolPrinter, Job, bool) {
+	for _, p := range printers {
+		spool, ok := p.(*SpoolPrinter)
+		if !ok {
+			continue
+		}
+		if job, ok := spool.Job(id); ok {
+			return spool, job, true
+		}
+	}
+	return nil, Job{}, false
+}
@@ -0,0 +1,243 @@
+package printer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// fakePrinter 记录打印的原始指令，离线时返回 ErrPrinterOffline
+type fakePrinter struct {
+	mu      sync.Mutex
+	offline bool
+	delay   time.Duration
+	printed []string
+	pulses  int
+}
+
+func (p *fakePrinter) setOffline(offline bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.offline = offline
+}
+
+func (p *fakePrinter) jobs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.printed)
+}
+
+func (p *fakePrinter) do(f func()) error {
+	p.mu.Lock()
+	delay := p.delay
+	p.mu.Unlock()
+	time.Sleep(delay)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.offline {
+		return fmt.Errorf("%w: connection refused", eprinter.ErrPrinterOffline)
+	}
+	f()
+	return nil
+}
+
+func (p *fakePrinter) OpenCashBox() error {
+	return p.do(func() { p.pulses++ })
+}
+
+func (p *fakePrinter) PrintRasterImage(img *raster.RasterImage) error {
+	return p.do(func() { p.printed = append(p.printed, "image") })
+}
+
+func (p *fakePrinter) PrintRaw(data []byte) error {
+	return p.do(func() { p.printed = append(p.printed, string(data)) })
+}
+
+func newDurableSpool(t *testing.T, p eprinter.EPrinter, dir string) *eprinter.SpoolPrinter {
+	t.Helper()
+	s, err := eprinter.NewDurableSpoolPrinter("p1", p, 8, dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// waitDone 等待任务打印完成
+func waitDone(t *testing.T, s *eprinter.SpoolPrinter, id string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	job, err := s.Wait(ctx, id)
+	if err != nil || job.State != eprinter.JobDone {
+		t.Fatalf("job %s: state %s, err %v, want done", id, job.State, err)
+	}
+}
+
+func TestSpoolPrinterOrder(t *testing.T) {
+	p := &fakePrinter{delay: 5 * time.Millisecond}
+	s := eprinter.NewSpoolPrinter("p1", p, 8)
+	defer s.Close()
+
+	var want, ids []string
+	for i := range 5 {
+		data := fmt.Sprintf("job %d", i)
+		id, err := s.Submit(eprinter.NewRawJob([]byte(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, data)
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		waitDone(t, s, id)
+	}
+	if got := p.jobs(); !slices.Equal(got, want) {
+		t.Errorf("printed %q, want %q", got, want)
+	}
+}
+
+func TestSpoolPrinterOfflineWithoutStore(t *testing.T) {
+	p := &fakePrinter{offline: true}
+	s := eprinter.NewSpoolPrinter("p1", p, 8)
+	defer s.Close()
+
+	err := s.PrintRaw([]byte("job 1"))
+	if !errors.Is(err, eprinter.ErrPrinterOffline) || errors.Is(err, eprinter.ErrJobDeferred) {
+		t.Fatalf("PrintRaw = %v, want ErrPrinterOffline without retry", err)
+	}
+}
+
+func TestSpoolPrinterRetry(t *testing.T) {
+	p := &fakePrinter{offline: true}
+	s := newDurableSpool(t, p, t.TempDir())
+
+	// 第一个任务失败后转为后台重试，后面的任务不等待重试，直接排在后面
+	id1, err := s.Submit(eprinter.NewRawJob([]byte("job 1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := s.PrintRaw([]byte("job 2")); !errors.Is(err, eprinter.ErrJobDeferred) {
+		t.Fatalf("PrintRaw while retrying = %v, want ErrJobDeferred", err)
+	}
+	if err := s.OpenCashBox(); !errors.Is(err, eprinter.ErrPrinterOffline) {
+		t.Fatalf("OpenCashBox while retrying = %v, want ErrPrinterOffline", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("jobs waited %v behind the retrying job", elapsed)
+	}
+	job, _ := s.Job(id1)
+	if job.State != eprinter.JobRetrying || job.Attempts == 0 {
+		t.Errorf("job 1: state %s, attempts %d, want retrying", job.State, job.Attempts)
+	}
+
+	p.setOffline(false)
+	waitDone(t, s, id1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, job := range s.Jobs() {
+		if job.Kind == eprinter.JobRaw && job.ID != id1 {
+			if _, err := s.Wait(ctx, job.ID); err != nil {
+				t.Fatalf("job 2: %v", err)
+			}
+		}
+	}
+	if got, want := p.jobs(), []string{"job 1", "job 2"}; !slices.Equal(got, want) {
+		t.Errorf("printed %q, want %q", got, want)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pulses != 0 {
+		t.Errorf("cash drawer opened %d times after the printer came back", p.pulses)
+	}
+}
+
+func TestSpoolPrinterResumeAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	p := &fakePrinter{offline: true}
+	s, err := eprinter.NewDurableSpoolPrinter("p1", p, 8, dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PrintRaw([]byte("job 1")); !errors.Is(err, eprinter.ErrJobDeferred) {
+		t.Fatalf("PrintRaw = %v, want ErrJobDeferred", err)
+	}
+	s.Close()
+	<-s.Done()
+
+	// 重启后从磁盘加载未完成的任务，打印机恢复后打印
+	p.setOffline(false)
+	s = newDurableSpool(t, p, dir)
+	jobs := s.Jobs()
+	if len(jobs) != 1 {
+		t.Fatalf("loaded %d jobs, want 1", len(jobs))
+	}
+	waitDone(t, s, jobs[0].ID)
+	if got, want := p.jobs(), []string{"job 1"}; !slices.Equal(got, want) {
+		t.Errorf("printed %q, want %q", got, want)
+	}
+}
+
+// panicPrinter 打印时panic，模拟打印机或图像处理中的错误
+type panicPrinter struct{ fakePrinter }
+
+func (p *panicPrinter) PrintRaw(data []byte) error {
+	panic("bad data")
+}
+
+func TestSpoolPrinterPanic(t *testing.T) {
+	dir := t.TempDir()
+	p := &panicPrinter{}
+	s := newDurableSpool(t, p, dir)
+
+	if err := s.PrintRaw([]byte("job 1")); !errors.Is(err, eprinter.ErrJobPanic) {
+		t.Fatalf("PrintRaw = %v, want ErrJobPanic", err)
+	}
+	// 队列继续处理后面的任务
+	if err := s.OpenCashBox(); err != nil {
+		t.Fatalf("OpenCashBox after a crashed job: %v", err)
+	}
+	s.Close()
+	<-s.Done()
+
+	// 崩溃的任务已从磁盘队列中删除，重启后不会再次加载
+	s = newDurableSpool(t, p, dir)
+	if jobs := s.Jobs(); len(jobs) != 0 {
+		t.Errorf("loaded %d jobs after restart, want 0", len(jobs))
+	}
+}
+
+func TestSpoolPrinterHandover(t *testing.T) {
+	dir := t.TempDir()
+	p := &fakePrinter{offline: true, delay: 200 * time.Millisecond}
+	s, err := eprinter.NewDurableSpoolPrinter("p1", p, 8, dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		if _, err := s.Submit(eprinter.NewRawJob(fmt.Appendf(nil, "job %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 离线打印机排队的任务不再逐个尝试连接，留在磁盘上交给新的打印机
+	start := time.Now()
+	s.Handover()
+	<-s.Done()
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Errorf("handover waited %v for the offline queue", elapsed)
+	}
+	p.setOffline(false)
+	s = newDurableSpool(t, p, dir)
+	if jobs := s.Jobs(); len(jobs) != 5 {
+		t.Fatalf("loaded %d jobs, want 5", len(jobs))
+	}
+}
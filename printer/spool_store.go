@@ -0,0 +1,125 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+// spoolStore 将未完成的打印任务保存在磁盘上，服务重启后可以继续打印
+// 每个任务保存为两个文件：<id>.json 保存元数据，<id>.png 或 <id>.bin 保存打印数据
+type spoolStore struct {
+	dir string
+}
+
+// spoolMeta 磁盘上保存的任务元数据
+type spoolMeta struct {
+	Job
+	Align string             `json:"align,omitempty"` // 光栅图像的对齐方式
+	Color string             `json:"color,omitempty"` // 光栅图像的颜色
+	Bands []raster.ColorBand `json:"bands,omitempty"` // 光栅图像中颜色不同的各段
+}
+
+func newSpoolStore(dir string) (*spoolStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir %s: %w", dir, err)
+	}
+	return &spoolStore{dir: dir}, nil
+}
+
+func (s *spoolStore) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// save 保存任务，先写数据文件再写元数据，元数据存在即表示任务完整
+func (s *spoolStore) save(job *Job) error {
+	if !job.durable() {
+		return nil
+	}
+	meta := spoolMeta{Job: job.snapshot()}
+	switch job.Kind {
+	case JobRaster:
+		meta.Align = job.Image.Align
+		meta.Color = job.Image.Color
+		meta.Bands = job.Image.Bands
+		if err := job.Image.SaveToPngFile(s.path(job.ID, ".png")); err != nil {
+			return err
+		}
+	case JobRaw:
+		if err := os.WriteFile(s.path(job.ID, ".bin"), job.Data, 0644); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(job.ID, ".json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(job.ID, ".json"))
+}
+
+// remove 删除任务的所有文件
+func (s *spoolStore) remove(id string) {
+	for _, ext := range []string{".json", ".png", ".bin"} {
+		os.Remove(s.path(id, ext))
+	}
+}
+
+// load 读取磁盘上所有未完成的任务，按创建时间排序
+func (s *spoolStore) load() []*Job {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var jobs []*Job
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		data, err := os.ReadFile(s.path(id, ".json"))
+		if err != nil {
+			continue
+		}
+		var meta spoolMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			fmt.Printf("Invalid spool file %s: %v\n", entry.Name(), err)
+			s.remove(id)
+			continue
+		}
+		job := meta.Job
+		switch job.Kind {
+		case JobRaster:
+			job.Image = raster.NewRasterImageFromFile(s.path(id, ".png"))
+			if job.Image == nil {
+				s.remove(id)
+				continue
+			}
+			job.Image.Align = meta.Align
+			job.Image.Color = meta.Color
+			job.Image.Bands = meta.Bands
+		case JobRaw:
+			job.Data, err = os.ReadFile(s.path(id, ".bin"))
+			if err != nil {
+				s.remove(id)
+				continue
+			}
+		default:
+			s.remove(id)
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
@@ -0,0 +1,234 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+var ErrStatusUnsupported = errors.New("printer does not support status query")
+
+const statusTimeout = 2 * time.Second // 等待打印机返回状态的时间
+
+// 实时状态查询指令 DLE EOT n，打印机离线时也会立即响应
+var dleEOT = [][]byte{
+	{0x10, 0x04, 0x01}, // 打印机状态
+	{0x10, 0x04, 0x02}, // 脱机原因
+	{0x10, 0x04, 0x03}, // 错误原因
+	{0x10, 0x04, 0x04}, // 纸张传感器状态
+}
+
+// 自动状态返回 GS a n，开启后打印机立即返回4字节状态
+var (
+	asbEnable  = []byte{0x1D, 0x61, 0xFF}
+	asbDisable = []byte{0x1D, 0x61, 0x00}
+)
+
+// PrinterStatus 打印机实时状态
+type PrinterStatus struct {
+	Online               bool `json:"online"`                // 在线
+	CoverOpen            bool `json:"cover_open"`            // 上盖打开
+	PaperEnd             bool `json:"paper_end"`             // 缺纸
+	PaperNearEnd         bool `json:"paper_near_end"`        // 纸将尽
+	PaperFeed            bool `json:"paper_feed"`            // 正在按走纸键
+	DrawerOpen           bool `json:"drawer_open"`           // 钱箱接口第3脚为高电平
+	MechanicalError      bool `json:"mechanical_error"`      // 机械错误
+	AutoCutterError      bool `json:"autocutter_error"`      // 切刀错误
+	UnrecoverableError   bool `json:"unrecoverable_error"`   // 不可恢复错误
+	AutoRecoverableError bool `json:"autorecoverable_error"` // 可自动恢复错误
+}
+
+// StatusPrinter 支持实时状态查询的打印机
+type StatusPrinter interface {
+	Status() (PrinterStatus, error)
+}
+
+// Status 查询打印机状态，打印机不支持时返回 ErrStatusUnsupported
+func Status(p EPrinter) (PrinterStatus, error) {
+	if sp, ok := p.(StatusPrinter); ok {
+		return sp.Status()
+	}
+	return PrinterStatus{}, ErrStatusUnsupported
+}
+
+func (s PrinterStatus) String() string {
+	var flags []string
+	if !s.Online {
+		flags = append(flags, "offline")
+	}
+	if s.CoverOpen {
+		flags = append(flags, "cover open")
+	}
+	if s.PaperEnd {
+		flags = append(flags, "paper end")
+	} else if s.PaperNearEnd {
+		flags = append(flags, "paper near end")
+	}
+	if s.AutoCutterError {
+		flags = append(flags, "cutter error")
+	}
+	if s.MechanicalError {
+		flags = append(flags, "mechanical error")
+	}
+	if s.UnrecoverableError {
+		flags = append(flags, "unrecoverable error")
+	}
+	if len(flags) == 0 {
+		return "ready"
+	}
+	return strings.Join(flags, ", ")
+}
+
+// Err 将状态转换为打印机错误，打印机可以正常打印时返回nil
+func (s PrinterStatus) Err() error {
+	switch {
+	case s.CoverOpen:
+		return ErrCoverOpen
+	case s.PaperEnd:
+		return ErrPaperEnd
+	case s.AutoCutterError:
+		return ErrAutoCutter
+	case !s.Online || s.MechanicalError || s.UnrecoverableError || s.AutoRecoverableError:
+		return fmt.Errorf("%w: %s", ErrPrinterOffline, s)
+	}
+	return nil
+}
+
+// ASB 返回ePOS-Print响应中的状态位
+func (s PrinterStatus) ASB() uint32 {
+	var asb uint32
+	flags := []struct {
+		set bool
+		bit uint32
+	}{
+		{!s.Online, raster.ASB_OFF_LINE},
+		{s.CoverOpen, raster.ASB_COVER_OPEN},
+		{s.PaperEnd, raster.ASB_RECEIPT_END},
+		{s.PaperNearEnd, raster.ASB_RECEIPT_NEAR_END},
+		{s.PaperFeed, raster.ASB_PAPER_FEED},
+		{s.DrawerOpen, raster.ASB_DRAWER_KICK},
+		{s.MechanicalError, raster.ASB_MECHANICAL_ERR},
+		{s.AutoCutterError, raster.ASB_AUTOCUTTER_ERR},
+		{s.UnrecoverableError, raster.ASB_UNRECOVER_ERR},
+		{s.AutoRecoverableError, raster.ASB_AUTORECOVER_ERR},
+	}
+	for _, f := range flags {
+		if f.set {
+			asb |= f.bit
+		}
+	}
+	return asb
+}
+
+// parseASB 解析GS a返回的4字节自动状态
+func parseASB(b [4]byte) PrinterStatus {
+	return PrinterStatus{
+		DrawerOpen:           b[0]&0x04 != 0,
+		Online:               b[0]&0x08 == 0,
+		CoverOpen:            b[0]&0x20 != 0,
+		PaperFeed:            b[0]&0x40 != 0,
+		MechanicalError:      b[1]&0x04 != 0,
+		AutoCutterError:      b[1]&0x08 != 0,
+		UnrecoverableError:   b[1]&0x20 != 0,
+		AutoRecoverableError: b[1]&0x40 != 0,
+		PaperNearEnd:         b[2]&0x03 != 0,
+		PaperEnd:             b[2]&0x0C != 0,
+	}
+}
+
+// parseDLEEOT 解析DLE EOT 1~4的响应，resp[i]为DLE EOT i+1的响应字节
+func parseDLEEOT(resp []byte) PrinterStatus {
+	s := PrinterStatus{Online: true}
+	for i, b := range resp {
+		switch i {
+		case 0:
+			s.DrawerOpen = b&0x04 != 0
+			s.Online = b&0x08 == 0
+		case 1:
+			s.CoverOpen = b&0x04 != 0
+			s.PaperFeed = b&0x08 != 0
+			s.PaperEnd = b&0x20 != 0
+		case 2:
+			s.MechanicalError = b&0x04 != 0
+			s.AutoCutterError = b&0x08 != 0
+			s.UnrecoverableError = b&0x20 != 0
+			s.AutoRecoverableError = b&0x40 != 0
+		case 3:
+			s.PaperNearEnd = b&0x0C != 0
+			s.PaperEnd = s.PaperEnd || b&0x60 != 0
+		}
+	}
+	return s
+}
+
+// queryStatus 向打印机发送DLE EOT 1~4和GS a，并在statusTimeout内读取响应。
+// DLE EOT是实时指令，打印机离线时也会响应；GS a在打印机可以处理指令时返回更完整的自动状态。
+// 打印机只需支持其中一种，两种响应可以通过固定位区分：DLE EOT响应为0xx1xx10，ASB第一个字节为0xx1xx00，其余三个字节第4位为0。
+// 读取可能会阻塞，调用方需要在返回后关闭设备以结束读取goroutine
+func queryStatus(rw io.ReadWriter) (PrinterStatus, error) {
+	var cmd []byte
+	for _, c := range dleEOT {
+		cmd = append(cmd, c...)
+	}
+	cmd = append(cmd, asbEnable...)
+	if _, err := rw.Write(cmd); err != nil {
+		return PrinterStatus{}, fmt.Errorf("%w: %w", ErrPrinterOffline, err)
+	}
+
+	chunks := make(chan []byte)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := rw.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- append([]byte(nil), buf[:n]...):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var eot []byte
+	var asb []byte
+	timer := time.NewTimer(statusTimeout)
+	defer timer.Stop()
+read:
+	for len(eot) < len(dleEOT) && len(asb) < 4 { // 任意一种响应完整即可
+		select {
+		case chunk := <-chunks:
+			for _, b := range chunk {
+				switch {
+				case len(asb) > 0 && len(asb) < 4:
+					asb = append(asb, b)
+				case b&0x93 == 0x12 && len(eot) < len(dleEOT):
+					eot = append(eot, b)
+				case b&0x93 == 0x10 && len(asb) == 0:
+					asb = append(asb, b)
+				}
+			}
+		case <-timer.C:
+			break read
+		}
+	}
+	rw.Write(asbDisable)
+
+	switch {
+	case len(asb) == 4:
+		return parseASB([4]byte(asb)), nil
+	case len(eot) > 0:
+		return parseDLEEOT(eot), nil
+	default:
+		return PrinterStatus{}, fmt.Errorf("%w: no status response", ErrTimeout)
+	}
+}
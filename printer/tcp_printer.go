@@ -3,12 +3,21 @@ package printer
 import (
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/xiaohao0576/odoo-epos/raster"
 	"github.com/xiaohao0576/odoo-epos/transformer"
 )
 
+const (
+	defaultConnectTimeout = 5 * time.Second  // 连接打印机的超时时间
+	defaultWriteTimeout   = 10 * time.Second // 每次写入的超时时间
+	defaultKeepAlive      = 30 * time.Second // TCP keepalive探测间隔
+	defaultIdleTimeout    = 30 * time.Second // 持久连接的空闲时间，大多数打印机网卡只接受一个连接，不能一直占用
+	tcpWriteChunk         = 4096             // 每次写入的最大字节数，超时按块计算，打印长图像时不会因总时间过长而超时
+)
+
 type TCPPrinter struct {
 	paperWidth        int                         // 纸张宽度
 	marginBottom      int                         // 下边距
@@ -17,12 +26,32 @@ type TCPPrinter struct {
 	HostPort          string                      // 打印机地址
 	fd                net.Conn                    // 直接用 net.Conn
 	transformer       transformer.TransformerFunc // 用于转换图像的转换器
+	twoColor          bool                        // 是否支持双色打印
+	commands          CommandSet                  // 指令集
+	fit               fitFunc                     // 调整图像到纸张宽度，见FitModes
+	bannerWidth       int                         // 多份打印时每份前面 "COPY n/N" 标题的宽度，0为不打印
+
+	persistent     bool          // 任务之间保持连接
+	connectTimeout time.Duration // 连接超时
+	writeTimeout   time.Duration // 每次写入的超时
+	keepAlive      time.Duration // TCP keepalive探测间隔
+	idleTimeout    time.Duration // 持久连接空闲多久后断开，0表示使用默认值，小于0表示不断开
+	mu             sync.Mutex    // 保护fd，持久连接的空闲定时器在其他goroutine中关闭连接
+	reused         bool          // 当前任务使用的是上次保留的连接
+	sent           int           // 当前任务已经写入的字节数
+	lastUsed       time.Time     // 持久连接最后一次使用的时间
+	idleTimer      *time.Timer
 }
 
 func (p *TCPPrinter) String() string {
 	return fmt.Sprintf("TCPPrinter{HostPort: %s, paperWidth: %d, marginBottom: %d}", p.HostPort, p.paperWidth, p.marginBottom)
 }
 
+func (p *TCPPrinter) PaperWidth() int {
+	return p.paperWidth
+}
+
+// Open 建立新的连接，已有的连接先关闭
 func (p *TCPPrinter) Open() error {
 	if p.HostPort == "" {
 		return net.ErrClosed
@@ -30,14 +59,33 @@ func (p *TCPPrinter) Open() error {
 	if p.fd != nil {
 		p.fd.Close()
 	}
-	conn, err := net.DialTimeout("tcp", p.HostPort, 5*time.Second)
+	dialer := net.Dialer{
+		Timeout: orDefault(p.connectTimeout, defaultConnectTimeout),
+		KeepAliveConfig: net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     orDefault(p.keepAlive, defaultKeepAlive),
+			Interval: orDefault(p.keepAlive, defaultKeepAlive),
+			Count:    3,
+		},
+	}
+	conn, err := dialer.Dial("tcp", p.HostPort)
 	if err != nil {
-		return err
+		p.fd = nil
+		return fmt.Errorf("%w: %w", ErrPrinterOffline, err)
 	}
 	p.fd = conn
+	p.reused = false
 	return nil
 }
 
+// orDefault 返回d，d未设置时返回默认值
+func orDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
 func (p *TCPPrinter) Close() error {
 	if p.fd != nil {
 		err := p.fd.Close()
@@ -47,54 +95,168 @@ func (p *TCPPrinter) Close() error {
 	return nil
 }
 
-func (p *TCPPrinter) OpenCashBox() error {
-	if p.fd == nil {
-		if err := p.Open(); err != nil {
+// acquire 取得连接并加锁，持久连接模式下复用仍然有效的连接，使用完后必须调用release
+func (p *TCPPrinter) acquire() error {
+	p.mu.Lock()
+	p.sent = 0
+	if p.persistent && p.fd != nil && p.alive() {
+		p.reused = true
+		return nil
+	}
+	if err := p.Open(); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// release 任务结束后释放连接，非持久连接模式或出错时关闭连接，下次重新连接
+func (p *TCPPrinter) release(err error) {
+	defer p.mu.Unlock()
+	if !p.persistent || err != nil {
+		p.Close()
+		return
+	}
+	p.lastUsed = time.Now()
+	if idle := orDefault(p.idleTimeout, defaultIdleTimeout); p.idleTimeout >= 0 {
+		if p.idleTimer == nil {
+			p.idleTimer = time.AfterFunc(idle, p.closeIdle)
+		} else {
+			p.idleTimer.Reset(idle)
+		}
+	}
+}
+
+// Disconnect 关闭保留的持久连接，打印机被删除或配置改变时由打印队列调用
+func (p *TCPPrinter) Disconnect() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.idleTimer != nil {
+		p.idleTimer.Stop()
+	}
+	p.Close()
+}
+
+// closeIdle 关闭空闲的持久连接，让其他客户端也可以连接打印机
+func (p *TCPPrinter) closeIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.lastUsed) >= orDefault(p.idleTimeout, defaultIdleTimeout) {
+		p.Close()
+	}
+}
+
+// alive 检查保留的连接是否已被打印机关闭或重置，同时丢弃连接中残留的状态响应。
+// 打印机断电时连接不会立即关闭，由TCP keepalive和写入超时发现
+func (p *TCPPrinter) alive() bool {
+	p.fd.SetReadDeadline(time.Now().Add(time.Millisecond))
+	buf := make([]byte, 64)
+	for {
+		if _, err := p.fd.Read(buf); err != nil {
+			return IsTimeout(err)
+		}
+	}
+}
+
+// write 分块写入数据，每块设置写入超时，打印机停止接收数据时返回超时错误而不是一直阻塞。
+// 复用的连接在写入任何数据前失败时重新连接一次。
+// 任务已经有数据发送到打印机后出错不能重试，否则打印机会重复打印已经收到的部分
+func (p *TCPPrinter) write(data []byte) error {
+	written := 0
+	for written < len(data) {
+		n := min(len(data)-written, tcpWriteChunk)
+		p.fd.SetWriteDeadline(time.Now().Add(orDefault(p.writeTimeout, defaultWriteTimeout)))
+		n, err := p.fd.Write(data[written : written+n])
+		written += n
+		p.sent += n
+		if err != nil && p.reused && p.sent == 0 {
+			if err := p.Open(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil && p.sent > 0 {
+			return fmt.Errorf("write interrupted after %d bytes: %v", p.sent, err)
+		}
+		if err != nil {
 			return err
 		}
 	}
-	defer p.Close() // 确保在函数结束时关闭连接
+	return nil
+}
+
+// Status 通过DLE EOT和GS a查询打印机实时状态
+func (p *TCPPrinter) Status() (status PrinterStatus, err error) {
+	if !p.commands.RealTimeStatus() {
+		return PrinterStatus{}, ErrStatusUnsupported
+	}
+	if err := p.acquire(); err != nil {
+		return PrinterStatus{}, err
+	}
+	defer func() { p.release(err) }()
+	p.fd.SetDeadline(time.Now().Add(statusTimeout))
+	status, err = queryStatus(p.fd)
+	// 结束queryStatus中的读取goroutine，持久连接下次使用前由alive丢弃迟到的响应
+	p.fd.SetReadDeadline(time.Now())
+	return status, err
+}
+
+func (p *TCPPrinter) OpenCashBox() (err error) {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	defer func() { p.release(err) }()
 	// 发送打开钱箱的命令
-	_, err := p.fd.Write(p.cashDrawerCommand)
-	return err
+	return p.write(p.cashDrawerCommand)
 }
 
 func (p *TCPPrinter) PrintRasterImage(img *raster.RasterImage) error {
+	return p.PrintRasterCopies(img, 1)
+}
+
+// PrintRasterCopies 打印copies份图像，每页的打印数据只生成一次，每份之间切纸
+func (p *TCPPrinter) PrintRasterCopies(img *raster.RasterImage, copies int) (err error) {
 	img = p.transformer(img) // 使用转换器转换图像
 	if img == nil {
 		return nil // 如果转换器返回 nil，表示不需要打印图像
 	}
-	if p.fd == nil {
-		if err := p.Open(); err != nil {
-			return err
-		}
-	}
-	defer p.Close()
+	var pages [][]byte
 	for _, page := range img.CutPages() {
+		page = p.fit(page) // 比纸张宽的图像按配置裁剪或缩放
 		page.AutoMarginLeft(p.paperWidth)
 		page.AddMarginBottom(p.marginBottom)
-		if _, err := p.fd.Write(page.ToEscPosRasterCommand(1024)); err != nil {
-			return err
-		}
-		if _, err := p.fd.Write(p.cutCommand); err != nil {
+		pages = append(pages, append(p.commands.Raster(page, p.twoColor), p.cutCommand...)) // 切纸命令
+	}
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	defer func() { p.release(err) }()
+	if err := p.write(p.commands.Init()); err != nil {
+		return err
+	}
+	for _, data := range repeatCopies(pages, copies, p.commands, p.bannerWidth) {
+		if err := p.write(data); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return p.write(p.commands.Trailer())
 }
 
-func (p *TCPPrinter) PrintRaw(data []byte) error {
-	if p.fd == nil {
-		if err := p.Open(); err != nil {
-			return err
-		}
-	}
-	defer p.Close()
+// PrintEscPosCopies 打印ePOS文档转换的ESC/POS指令，前后加上打印机设置
+func (p *TCPPrinter) PrintEscPosCopies(data []byte, copies int) error {
+	return p.PrintRaw(escposCopies(p.commands, data, copies))
+}
+
+func (p *TCPPrinter) PrintRaw(data []byte) (err error) {
 	if len(data) == 0 {
 		return fmt.Errorf("no data to print")
 	}
-	if _, err := p.fd.Write(data); err != nil {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	defer func() { p.release(err) }()
+	if err := p.write(data); err != nil {
 		return fmt.Errorf("failed to write data to printer: %w", err)
 	}
 	return nil
@@ -0,0 +1,196 @@
+package printer_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+	"github.com/xiaohao0576/odoo-epos/printer/printertest"
+	"github.com/xiaohao0576/odoo-epos/raster"
+)
+
+func TestTCPPrinterPrintRaw(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	config := s.Config()
+	p := config.NewPrinter()
+
+	if err := p.PrintRaw([]byte("hello\n")); err != nil {
+		t.Fatalf("PrintRaw: %v", err)
+	}
+	jobs, err := s.WaitJobs(1, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(jobs[0].Data, []byte("hello\n")) {
+		t.Errorf("printer received %q, want it to contain %q", jobs[0].Data, "hello\n")
+	}
+	if jobs[0].Image == nil {
+		t.Error("emulator rendered no image")
+	}
+}
+
+func TestTCPPrinterProfile(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	config := s.Config()
+	config.Profile = &eprinter.PrinterProfile{LineSpacing: 30, Beep: 1}
+	spool := eprinter.NewSpoolPrinter("p1", config.NewPrinter(), 0)
+	defer spool.Close()
+
+	// 原始指令（TSPL标签、/eprint/raw）原样发送，不加打印机设置
+	if err := spool.PrintRaw([]byte("SIZE 40 mm,30 mm\r\n")); err != nil {
+		t.Fatalf("PrintRaw: %v", err)
+	}
+	if _, err := s.WaitJobs(1, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// ePOS文档转换的ESC/POS指令加上打印机设置，指令开头的 ESC @ 不再清除设置
+	if err := spool.PrintEscPosCopies([]byte("\x1b@hello\n"), 1); err != nil {
+		t.Fatalf("PrintEscPosCopies: %v", err)
+	}
+	jobs, err := s.WaitJobs(2, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(jobs[0].Data), "SIZE 40 mm,30 mm\r\n"; got != want {
+		t.Errorf("raw job: printer received %q, want %q", got, want)
+	}
+	if got, want := string(jobs[1].Data), "\x1b@\x1b3\x1ehello\n\x1bB\x01\x03"; got != want {
+		t.Errorf("ePOS job: printer received %q, want %q", got, want)
+	}
+}
+
+func TestTCPPrinterPrintRasterImage(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	s.SetBehavior(printertest.Behavior{
+		Status: eprinter.PrinterStatus{Online: true},
+		Delay:  20 * time.Millisecond, // 慢速打印机也能收到完整的数据
+	})
+	config := s.Config()
+	p := config.NewPrinter()
+
+	img := raster.NewRasterImage(64, 32)
+	for y := range 32 {
+		for x := range 64 {
+			img.SetPixelBlack(x, y)
+		}
+	}
+	if err := p.PrintRasterImage(img); err != nil {
+		t.Fatalf("PrintRasterImage: %v", err)
+	}
+	jobs, err := s.WaitJobs(1, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs[0].Image == nil || jobs[0].Image.Height < 32 {
+		t.Fatalf("emulator rendered %v, want an image at least 32 dots high", jobs[0].Image)
+	}
+}
+
+func TestTCPPrinterStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status eprinter.PrinterStatus
+		want   error
+	}{
+		{"online", eprinter.PrinterStatus{Online: true}, nil},
+		{"paper end", eprinter.PrinterStatus{Online: true, PaperEnd: true}, eprinter.ErrPaperEnd},
+		{"cover open", eprinter.PrinterStatus{Online: true, CoverOpen: true}, eprinter.ErrCoverOpen},
+		{"cutter error", eprinter.PrinterStatus{Online: true, AutoCutterError: true}, eprinter.ErrAutoCutter},
+	}
+	s := printertest.NewServer()
+	defer s.Close()
+	config := s.Config()
+	p := config.NewPrinter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.SetStatus(tt.status)
+			status, err := eprinter.Status(p)
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+			if got := status.Err(); !errors.Is(got, tt.want) {
+				t.Errorf("status %v: Err() = %v, want %v", status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTCPPrinterStatusNoResponse(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	s.SetBehavior(printertest.Behavior{NoResponse: true})
+	config := s.Config()
+	p := config.NewPrinter()
+
+	if _, err := eprinter.Status(p); !eprinter.IsTimeout(err) {
+		t.Fatalf("Status = %v, want a timeout", err)
+	}
+	if s.Queries() == 0 {
+		t.Error("printer received no status query")
+	}
+}
+
+func TestTCPPrinterOffline(t *testing.T) {
+	s := printertest.NewServer()
+	config := s.Config()
+	s.Close()
+	p := config.NewPrinter()
+
+	err := p.PrintRaw([]byte("hello\n"))
+	if !errors.Is(err, eprinter.ErrPrinterOffline) {
+		t.Fatalf("PrintRaw = %v, want ErrPrinterOffline", err)
+	}
+	if !eprinter.IsRetryable(err) {
+		t.Error("offline error is not retryable")
+	}
+}
+
+func TestTCPPrinterReset(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	s.SetBehavior(printertest.Behavior{
+		Status:     eprinter.PrinterStatus{Online: true},
+		Delay:      10 * time.Millisecond,
+		ResetAfter: 1024,
+	})
+	config := s.Config()
+	p := config.NewPrinter()
+
+	// 数据需要超过系统发送缓冲区，写入才会发现连接已被重置
+	if err := p.PrintRaw(bytes.Repeat([]byte("x"), 8<<20)); err == nil {
+		t.Fatal("PrintRaw succeeded on a reset connection")
+	}
+	jobs, err := s.WaitJobs(1, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jobs[0].Reset || len(jobs[0].Data) != 1024 {
+		t.Errorf("printer received %d bytes (reset %v), want 1024 bytes and a reset", len(jobs[0].Data), jobs[0].Reset)
+	}
+}
+
+func TestTCPPrinterWriteTimeoutAfterData(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	s.SetBehavior(printertest.Behavior{
+		Status: eprinter.PrinterStatus{Online: true},
+		Delay:  2 * time.Second, // 打印机停止接收数据
+	})
+	config := s.Config()
+	config.WriteTimeout = 1
+	p := config.NewPrinter()
+
+	// 部分数据已经发送到打印机，重试会重复打印这部分
+	err := p.PrintRaw(bytes.Repeat([]byte("x"), 8<<20))
+	if err == nil {
+		t.Fatal("PrintRaw succeeded on a stalled printer")
+	}
+	if eprinter.IsRetryable(err) {
+		t.Errorf("PrintRaw = %v, want a non-retryable error after data was sent", err)
+	}
+}
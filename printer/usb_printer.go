@@ -4,6 +4,7 @@ package printer
 import (
 	"fmt"
 	"os"
+	"syscall"
 	"time"
 
 	"github.com/xiaohao0576/odoo-epos/raster"
@@ -15,28 +16,66 @@ type USBPrinter struct {
 	marginBottom      int                         // 下边距
 	cutCommand        []byte                      // 切纸命令
 	cashDrawerCommand []byte                      // 钱箱命令
-	filePath          string                      // USB打印机的文件路径
+	filePath          string                      // USB打印机的文件路径，或 usb:VID:PID[:SERIAL]
 	fd                *os.File                    // 文件描述符
 	transformer       transformer.TransformerFunc // 用于转换图像的转换器
+	twoColor          bool                        // 是否支持双色打印
+	commands          CommandSet                  // 指令集
+	fit               fitFunc                     // 调整图像到纸张宽度，见FitModes
+	completion        string                      // 确认打印完成的方式
+	bannerWidth       int                         // 多份打印时每份前面 "COPY n/N" 标题的宽度，0为不打印
 }
 
 func (p *USBPrinter) String() string {
 	return fmt.Sprintf("USBPrinter{devFile: %s, paperWidth: %d, marginBottom: %d}", p.filePath, p.paperWidth, p.marginBottom)
 }
 
+func (p *USBPrinter) PaperWidth() int {
+	return p.paperWidth
+}
+
 func (p *USBPrinter) Open() error {
 	if p.filePath == "" {
 		return os.ErrInvalid
 	}
-	var err error
-	p.fd, err = os.OpenFile(p.filePath, os.O_WRONLY, 0644)
+	devPath, err := resolveUSBDevice(p.filePath)
 	if err != nil {
-		fmt.Printf("Error opening USB printer: %v\n", err)
 		return err
 	}
+	flag := os.O_WRONLY
+	if p.completion != "" && p.completion != CompletionSleep {
+		// 需要读取完成响应，非阻塞方式打开，读取可以设置超时
+		flag = os.O_RDWR | syscall.O_NONBLOCK
+	}
+	p.fd, err = os.OpenFile(devPath, flag, 0644)
+	if err != nil {
+		fmt.Printf("Error opening USB printer: %v\n", err)
+		return fmt.Errorf("%w: %w", ErrPrinterOffline, err)
+	}
 	return nil
 }
 
+// Status 通过DLE EOT和GS a查询打印机实时状态，打印时只写不读，查询状态时需要以读写方式打开
+func (p *USBPrinter) Status() (PrinterStatus, error) {
+	if !p.commands.RealTimeStatus() {
+		return PrinterStatus{}, ErrStatusUnsupported
+	}
+	if p.filePath == "" {
+		return PrinterStatus{}, os.ErrInvalid
+	}
+	devPath, err := resolveUSBDevice(p.filePath)
+	if err != nil {
+		return PrinterStatus{}, err
+	}
+	// 非阻塞方式打开，关闭文件时可以结束读取
+	fd, err := os.OpenFile(devPath, os.O_RDWR|syscall.O_NONBLOCK, 0644)
+	if err != nil {
+		return PrinterStatus{}, fmt.Errorf("%w: %w", ErrPrinterOffline, err)
+	}
+	defer fd.Close()
+	return queryStatus(fd)
+}
+
 func (p *USBPrinter) OpenCashBox() error {
 	err := p.Reset()
 	if err != nil {
@@ -46,15 +85,29 @@ func (p *USBPrinter) OpenCashBox() error {
 		p.fd.Sync()
 		p.fd.Close()
 	}()
-	p.fd.Write(p.cashDrawerCommand)
+	if _, err := p.fd.Write(p.cashDrawerCommand); err != nil {
+		return fmt.Errorf("failed to write data to printer: %w", err)
+	}
 	return nil
 }
 
 func (p *USBPrinter) PrintRasterImage(img *raster.RasterImage) error {
+	return p.PrintRasterCopies(img, 1)
+}
+
+// PrintRasterCopies 打印copies份图像，每页的打印数据只生成一次，每份之间切纸
+func (p *USBPrinter) PrintRasterCopies(img *raster.RasterImage, copies int) error {
 	img = p.transformer(img) // 使用转换器转换图像
 	if img == nil {
 		return nil // 如果转换器返回 nil，表示不需要打印图像
 	}
+	var pages [][]byte
+	for _, page := range img.CutPages() {
+		page = p.fit(page) // 比纸张宽的图像按配置裁剪或缩放
+		page.AutoMarginLeft(p.paperWidth)
+		page.AddMarginBottom(p.marginBottom)
+		pages = append(pages, append(p.commands.Raster(page, p.twoColor), p.cutCommand...)) // 切纸命令
+	}
 	err := p.Reset()
 	if err != nil {
 		return fmt.Errorf("failed to reset printer: %w", err)
@@ -63,20 +116,41 @@ func (p *USBPrinter) PrintRasterImage(img *raster.RasterImage) error {
 		p.fd.Sync()
 		p.fd.Close()
 	}()
-	for _, page := range img.CutPages() {
-		page.AutoMarginLeft(p.paperWidth)
-		page.AddMarginBottom(p.marginBottom)
-		p.fd.Write(page.ToEscPosRasterCommand(1024))
-		p.fd.Write(p.cutCommand)    // 切纸命令
-		time.Sleep(1 * time.Second) // 等待打印机处理
+	for _, data := range repeatCopies(pages, copies, p.commands, p.bannerWidth) {
+		if _, err := p.fd.Write(data); err != nil {
+			return fmt.Errorf("failed to write data to printer: %w", err)
+		}
+		if p.completion == "" || p.completion == CompletionSleep {
+			time.Sleep(sleepPerPage) // 等待打印机处理
+		}
+	}
+	if _, err := p.fd.Write(p.commands.Trailer()); err != nil {
+		return fmt.Errorf("failed to write data to printer: %w", err)
 	}
 
-	return nil
+	return p.waitCompletion()
+}
+
+// waitCompletion 按completion配置等待打印机处理完已写入的数据，USB传送时间不计入超时。
+// sleep方式已经在每页之后等待过，这时以只写方式打开，不能设置读取超时
+func (p *USBPrinter) waitCompletion() error {
+	if p.completion == "" || p.completion == CompletionSleep {
+		return nil
+	}
+	if err := p.fd.SetReadDeadline(time.Time{}); err != nil {
+		// 设备不支持读取超时，无法等待响应，退回固定等待
+		fmt.Printf("USB printer %s does not support read deadline, sleeping instead: %v\n", p.filePath, err)
+		time.Sleep(sleepPerPage)
+		return nil
+	}
+	return waitCompletion(p.fd, p.completion, completionTimeout)
 }
 
 func (p *USBPrinter) Reset() error {
-	p.Open()
-	_, err := p.fd.Write([]byte{0x1B, 0x40}) // 初始化打印机
+	if err := p.Open(); err != nil {
+		return err
+	}
+	_, err := p.fd.Write(p.commands.Init()) // 初始化打印机
 	if err != nil {
 		p.fd.Sync()
 		p.fd.Close()
@@ -86,7 +160,15 @@ func (p *USBPrinter) Reset() error {
 	return nil
 }
 
+// PrintEscPosCopies 打印ePOS文档转换的ESC/POS指令，前后加上打印机设置
+func (p *USBPrinter) PrintEscPosCopies(data []byte, copies int) error {
+	return p.PrintRaw(escposCopies(p.commands, data, copies))
+}
+
 func (p *USBPrinter) PrintRaw(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("no data to print")
+	}
 	err := p.Open()
 	if err != nil {
 		return fmt.Errorf("failed to open printer: %w", err)
@@ -95,12 +177,11 @@ func (p *USBPrinter) PrintRaw(data []byte) error {
 		p.fd.Sync()
 		p.fd.Close()
 	}()
-	if len(data) == 0 {
-		return fmt.Errorf("no data to print")
-	}
 	if _, err := p.fd.Write(data); err != nil {
 		return fmt.Errorf("failed to write data to printer: %w", err)
 	}
-	time.Sleep(1 * time.Second) // 等待打印机处理
-	return nil
+	if p.completion == "" || p.completion == CompletionSleep {
+		time.Sleep(sleepPerPage) // 等待打印机处理
+	}
+	return p.waitCompletion()
 }
@@ -0,0 +1,142 @@
+package printer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 内核usblp驱动在sysfs中为每台USB打印机创建 /sys/class/usbmisc/lpN，
+// 其device链接指向USB接口，接口的上级目录是USB设备，包含idVendor、idProduct和serial
+var (
+	usbmiscDir = "/sys/class/usbmisc"
+	usbDevDirs = []string{"/dev/usb", "/dev"}
+)
+
+// usbAddress 以厂商ID、产品ID和序列号表示的USB打印机地址，如 usb:0483:5743 或 usb:0483:5743:ABC123
+type usbAddress struct {
+	vendor  string
+	product string
+	serial  string // 为空时匹配第一台厂商ID和产品ID相同的打印机
+}
+
+func (a usbAddress) String() string {
+	if a.serial != "" {
+		return fmt.Sprintf("usb:%s:%s:%s", a.vendor, a.product, a.serial)
+	}
+	return fmt.Sprintf("usb:%s:%s", a.vendor, a.product)
+}
+
+// parseUSBAddress 解析 usb:VID:PID[:SERIAL]，地址不是这种格式时ok为false
+func parseUSBAddress(address string) (addr usbAddress, ok bool, err error) {
+	rest, ok := strings.CutPrefix(address, "usb:")
+	if !ok {
+		return addr, false, nil
+	}
+	parts := strings.SplitN(rest, ":", 3)
+	if len(parts) < 2 || !isHexID(parts[0]) || !isHexID(parts[1]) {
+		return addr, true, fmt.Errorf("invalid usb address %q, expected usb:VID:PID[:SERIAL]", address)
+	}
+	addr = usbAddress{vendor: strings.ToLower(parts[0]), product: strings.ToLower(parts[1])}
+	if len(parts) == 3 {
+		addr.serial = parts[2]
+	}
+	return addr, true, nil
+}
+
+// isHexID 判断是否为4位十六进制的USB厂商ID或产品ID
+func isHexID(s string) bool {
+	if len(s) != 4 {
+		return false
+	}
+	for _, c := range strings.ToLower(s) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// usbDevice sysfs中的一台USB打印机
+type usbDevice struct {
+	name         string // lp0
+	devPath      string // /dev/usb/lp0
+	vendor       string
+	product      string
+	serial       string
+	manufacturer string
+	productName  string
+}
+
+// listUSBDevices 返回当前连接的所有USB打印机
+func listUSBDevices() []usbDevice {
+	entries, _ := os.ReadDir(usbmiscDir)
+	var devices []usbDevice
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "lp") {
+			continue
+		}
+		iface, err := filepath.EvalSymlinks(filepath.Join(usbmiscDir, name, "device"))
+		if err != nil {
+			continue
+		}
+		usbDir := filepath.Dir(iface)
+		read := func(attr string) string {
+			data, _ := os.ReadFile(filepath.Join(usbDir, attr))
+			return strings.TrimSpace(string(data))
+		}
+		device := usbDevice{
+			name:         name,
+			vendor:       strings.ToLower(read("idVendor")),
+			product:      strings.ToLower(read("idProduct")),
+			serial:       read("serial"),
+			manufacturer: read("manufacturer"),
+			productName:  read("product"),
+		}
+		for _, dir := range usbDevDirs {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				device.devPath = filepath.Join(dir, name)
+				break
+			}
+		}
+		if device.devPath != "" {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// resolveUSBDevice 返回打印机当前的设备文件，地址为设备文件路径时原样返回。
+// 每次打开打印机时重新查找，打印机插到其他USB口后设备文件改变也可以找到
+func resolveUSBDevice(address string) (string, error) {
+	addr, ok, err := parseUSBAddress(address)
+	if !ok || err != nil {
+		return address, err
+	}
+	for _, device := range listUSBDevices() {
+		if device.vendor == addr.vendor && device.product == addr.product &&
+			(addr.serial == "" || device.serial == addr.serial) {
+			return device.devPath, nil
+		}
+	}
+	return "", fmt.Errorf("%w: usb printer %s not connected", ErrPrinterOffline, addr)
+}
+
+// discoverUSBPrinters 返回当前连接的USB打印机，地址使用usb:VID:PID[:SERIAL]格式
+func discoverUSBPrinters() []DiscoveredPrinter {
+	var printers []DiscoveredPrinter
+	for _, device := range listUSBDevices() {
+		addr := usbAddress{vendor: device.vendor, product: device.product, serial: device.serial}
+		printers = append(printers, DiscoveredPrinter{
+			Name:    suggestName(device.productName, device.name),
+			Address: addr.String(),
+			Vendor:  device.manufacturer,
+			Model:   device.productName,
+			Source:  "usb",
+			Config:  ConfigPrinter{Type: "usb", Address: addr.String()},
+		})
+	}
+	return printers
+}
@@ -0,0 +1,272 @@
+package raster
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EAN左侧奇数字符集（L），偶数字符集（G）和右侧字符集（R）由L推导
+var eanLCodes = [10]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+// EAN-13首位数字决定左侧6位使用L还是G
+var eanParity = [10]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+// CODE39每个字符的9个条/空，1为宽
+var code39Codes = map[byte]string{
+	'0': "000110100", '1': "100100001", '2': "001100001", '3': "101100000", '4': "000110001",
+	'5': "100110000", '6': "001110000", '7': "000100101", '8': "100100100", '9': "001100100",
+	'A': "100001001", 'B': "001001001", 'C': "101001000", 'D': "000011001", 'E': "100011000",
+	'F': "001011000", 'G': "000001101", 'H': "100001100", 'I': "001001100", 'J': "000011100",
+	'K': "100000011", 'L': "001000011", 'M': "101000010", 'N': "000010011", 'O': "100010010",
+	'P': "001010010", 'Q': "000000111", 'R': "100000110", 'S': "001000110", 'T': "000010110",
+	'U': "110000001", 'V': "011000001", 'W': "111000000", 'X': "010010001", 'Y': "110010000",
+	'Z': "011010000", '-': "010000101", '.': "110000100", ' ': "011000100", '*': "010010100",
+	'$': "010101000", '/': "010100010", '+': "010001010", '%': "000101010",
+}
+
+// ITF每个数字的5个条（或空），1为宽
+var itfCodes = [10]string{"00110", "10001", "01001", "11000", "00101", "10100", "01100", "00011", "10010", "01010"}
+
+// CODE128每个值的条/空宽度（模块数），106为终止符
+var code128Codes = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+// appendBits 按"0101"形式的字符串添加模块
+func appendBits(modules []bool, bits string) []bool {
+	for _, c := range bits {
+		modules = append(modules, c == '1')
+	}
+	return modules
+}
+
+// appendWidths 按宽度字符串添加条和空，第一个为条
+func appendWidths(modules []bool, widths string) []bool {
+	for i, c := range widths {
+		for range int(c - '0') {
+			modules = append(modules, i%2 == 0)
+		}
+	}
+	return modules
+}
+
+func isDigits(data []byte) bool {
+	for _, c := range data {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(data) > 0
+}
+
+// eanModules 返回EAN-13或EAN-8（length为13或8）的模块和条码文字，
+// 数据少一位时自动计算校验位，数据无效时返回nil
+func eanModules(data []byte, length int) ([]bool, string) {
+	if !isDigits(data) || (len(data) != length && len(data) != length-1) {
+		return nil, string(data)
+	}
+	digits := []byte(string(data[:length-1]))
+	sum := 0
+	for i := range digits {
+		weight := 1
+		if (len(digits)-i)%2 == 1 {
+			weight = 3
+		}
+		sum += int(digits[i]-'0') * weight
+	}
+	digits = append(digits, byte('0'+(10-sum%10)%10))
+
+	modules := appendBits(nil, "101")
+	half := length / 2
+	left, right := digits[:half], digits[half:]
+	parity := strings.Repeat("L", half)
+	if length == 13 {
+		left, right = digits[1:7], digits[7:]
+		parity = eanParity[digits[0]-'0']
+	}
+	for i, d := range left {
+		code := eanLCodes[d-'0']
+		if parity[i] == 'G' {
+			code = reverse(invert(code))
+		}
+		modules = appendBits(modules, code)
+	}
+	modules = appendBits(modules, "01010")
+	for _, d := range right {
+		modules = appendBits(modules, invert(eanLCodes[d-'0']))
+	}
+	modules = appendBits(modules, "101")
+	return modules, string(digits)
+}
+
+func invert(bits string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '0' {
+			return '1'
+		}
+		return '0'
+	}, bits)
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// code39Modules 返回CODE39的模块和条码文字，没有起始/终止符'*'时自动添加，宽条为2个模块
+func code39Modules(data []byte) ([]bool, string) {
+	text := strings.Trim(string(data), "*")
+	var modules []bool
+	for i, c := range []byte("*" + text + "*") {
+		code, ok := code39Codes[c]
+		if !ok || (c == '*' && i != 0 && i != len(text)+1) {
+			return nil, string(data)
+		}
+		if i > 0 {
+			modules = append(modules, false) // 字符间隔
+		}
+		for j, wide := range code {
+			for range 1 + int(wide-'0') {
+				modules = append(modules, j%2 == 0)
+			}
+		}
+	}
+	return modules, "*" + text + "*"
+}
+
+// itfModules 返回ITF（交叉25码）的模块，数据必须是偶数位数字，宽条为2个模块
+func itfModules(data []byte) []bool {
+	if !isDigits(data) || len(data)%2 != 0 {
+		return nil
+	}
+	modules := appendBits(nil, "1010")
+	for i := 0; i < len(data); i += 2 {
+		bars, spaces := itfCodes[data[i]-'0'], itfCodes[data[i+1]-'0']
+		for j := range 5 {
+			modules = appendWidths(modules, fmt.Sprintf("%d%d", 1+int(bars[j]-'0'), 1+int(spaces[j]-'0')))
+		}
+	}
+	return appendWidths(modules, "211")
+}
+
+// code128Modules 按ESC/POS的格式解析CODE128数据并返回模块和条码文字：
+// 数据以"{A"、"{B"或"{C"选择字符集开始，"{S"为SHIFT，"{1"~"{4"为FNC1~FNC4，"{{"为'{'，
+// 字符集C中每个字节为0~99的值
+func code128Modules(data []byte) ([]bool, string) {
+	if len(data) < 2 || data[0] != '{' || data[1] < 'A' || data[1] > 'C' {
+		return nil, string(data)
+	}
+	set := data[1]
+	values := []int{103 + int(set-'A')}
+	var hri strings.Builder
+	value := func(set, c byte) (int, bool) {
+		switch set {
+		case 'A':
+			switch {
+			case c < 0x20:
+				return int(c) + 64, true
+			case c < 0x60:
+				return int(c) - 32, true
+			}
+		case 'B':
+			if c >= 0x20 && c < 0x80 {
+				return int(c) - 32, true
+			}
+		case 'C':
+			if c < 100 {
+				return int(c), true
+			}
+		}
+		return 0, false
+	}
+	for i := 2; i < len(data); i++ {
+		c := data[i]
+		if c == '{' && i+1 < len(data) {
+			i++
+			switch data[i] {
+			case 'A', 'B', 'C':
+				if data[i] != set {
+					values = append(values, map[byte]int{'A': 101, 'B': 100, 'C': 99}[data[i]])
+					set = data[i]
+				}
+				continue
+			case 'S':
+				values = append(values, 98)
+				if i+1 >= len(data) || set == 'C' {
+					return nil, string(data)
+				}
+				i++
+				shifted := byte('A')
+				if set == 'A' {
+					shifted = 'B'
+				}
+				v, ok := value(shifted, data[i])
+				if !ok {
+					return nil, string(data)
+				}
+				values = append(values, v)
+				hri.WriteByte(data[i])
+				continue
+			case '1':
+				values = append(values, 102)
+				continue
+			case '2':
+				values = append(values, 97)
+				continue
+			case '3':
+				values = append(values, 96)
+				continue
+			case '4':
+				if set == 'C' {
+					return nil, string(data)
+				}
+				values = append(values, map[byte]int{'A': 101, 'B': 100}[set])
+				continue
+			case '{':
+				c = '{'
+			default:
+				return nil, string(data)
+			}
+		}
+		v, ok := value(set, c)
+		if !ok {
+			return nil, string(data)
+		}
+		values = append(values, v)
+		if set == 'C' {
+			fmt.Fprintf(&hri, "%02d", c)
+		} else if c >= 0x20 {
+			hri.WriteByte(c)
+		}
+	}
+
+	checksum := values[0]
+	for i, v := range values[1:] {
+		checksum += (i + 1) * v
+	}
+	values = append(values, checksum%103, 106)
+	var modules []bool
+	for _, v := range values {
+		modules = appendWidths(modules, code128Codes[v])
+	}
+	return modules, hri.String()
+}
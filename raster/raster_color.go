@@ -0,0 +1,75 @@
+package raster
+
+import "strings"
+
+// ColorBand 图像中从第Y行开始Height行按Color打印，用于合并了不同颜色图片的文档
+type ColorBand struct {
+	Y      int    `json:"y"`
+	Height int    `json:"height"`
+	Color  string `json:"color,omitempty"`
+}
+
+// ColorBands 返回从上到下覆盖整个图像的颜色分段，没有分段时整个图像为一段，分段没有覆盖的行按Color打印
+func (img *RasterImage) ColorBands() []ColorBand {
+	var bands []ColorBand
+	add := func(y, end int, color string) {
+		if end <= y {
+			return
+		}
+		if n := len(bands); n > 0 && bands[n-1].Color == color && bands[n-1].Y+bands[n-1].Height == y {
+			bands[n-1].Height += end - y
+			return
+		}
+		bands = append(bands, ColorBand{Y: y, Height: end - y, Color: color})
+	}
+	y := 0
+	for _, band := range img.Bands {
+		start, end := max(band.Y, y), min(band.Y+band.Height, img.Height)
+		add(y, start, img.Color)
+		add(start, end, band.Color)
+		y = max(y, end)
+	}
+	add(y, img.Height, img.Color)
+	return bands
+}
+
+// IsColor 图像是否有需要以黑色以外的颜色打印的部分
+func (img *RasterImage) IsColor() bool {
+	for _, band := range img.ColorBands() {
+		if isColor(band.Color) {
+			return true
+		}
+	}
+	return false
+}
+
+func isColor(color string) bool {
+	color = strings.ToLower(color)
+	return color != "" && color != "color_1" && color != "none"
+}
+
+// bandsIn 返回第start到end行（不含）之间的颜色分段，行号从start开始计算
+func (img *RasterImage) bandsIn(start, end int) []ColorBand {
+	var bands []ColorBand
+	for _, band := range img.Bands {
+		y0, y1 := max(band.Y, start), min(band.Y+band.Height, end)
+		if y1 > y0 {
+			bands = append(bands, ColorBand{Y: y0 - start, Height: y1 - y0, Color: band.Color})
+		}
+	}
+	return bands
+}
+
+// scaleBands 返回图像高度从img.Height缩放到height后的颜色分段
+func (img *RasterImage) scaleBands(height int) []ColorBand {
+	if len(img.Bands) == 0 || img.Height <= 0 {
+		return nil
+	}
+	bands := make([]ColorBand, len(img.Bands))
+	for i, band := range img.Bands {
+		y := band.Y * height / img.Height
+		end := (band.Y + band.Height) * height / img.Height
+		bands[i] = ColorBand{Y: y, Height: end - y, Color: band.Color}
+	}
+	return bands
+}
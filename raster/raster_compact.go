@@ -0,0 +1,84 @@
+package raster
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// minBlankRows 连续空白行达到这个数量时才用走纸指令代替，避免把图像拆成太多小段使打印机在段之间停顿
+const minBlankRows = 24
+
+// ToCompactCommand 将图像中连续的空白行替换为feed生成的走纸指令，其余部分去掉右侧的空白字节后用encode编码，
+// 减少传送的数据量，在串口等慢速连接上打印更快。align为encode每次打印的行数，
+// 如ESC *每条24行，每段的高度补齐到align的倍数，补齐的行从后面的空白行中扣除
+func (img *RasterImage) ToCompactCommand(encode func(*RasterImage) []byte, align int, feed func(rows int) []byte) []byte {
+	if img == nil || img.Width <= 0 || img.Height <= 0 || img.Content == nil {
+		return nil
+	}
+	align = max(align, 1)
+	widthBytes := img.Width / 8
+	var result []byte
+	y := 0
+	for y < img.Height {
+		blank := img.blankRows(y)
+		if blank >= minBlankRows || y+blank == img.Height {
+			result = append(result, feed(blank)...)
+			y += blank
+			continue
+		}
+		// 内容段到下一段足够长的空白行为止
+		end := y
+		for end < img.Height {
+			if blank := img.blankRows(end); blank >= minBlankRows || end+blank == img.Height {
+				break
+			}
+			end++
+		}
+		height := end - y
+		pad := (align - height%align) % align
+		height += min(pad, img.Height-end)
+
+		// 去掉右侧所有行都为空白的字节
+		used := 1
+		for row := y; row < end; row++ {
+			used = max(used, len(bytes.TrimRight(img.GetRow(row), "\x00")))
+		}
+		segment := NewRasterImage(used*8, height)
+		for row := y; row < end; row++ {
+			copy(segment.Content[(row-y)*used:], img.Content[row*widthBytes:row*widthBytes+used])
+		}
+		result = append(result, encode(segment)...)
+		y += height
+	}
+	return result
+}
+
+// blankRows 返回从第y行开始连续的空白行数
+func (img *RasterImage) blankRows(y int) int {
+	n := 0
+	for y+n < img.Height && !bytes.ContainsFunc(img.GetRow(y+n), func(r rune) bool { return r != 0 }) {
+		n++
+	}
+	return n
+}
+
+// EscPosFeed 使用 ESC J n 走纸rows点，单条指令最多255点。
+// ESC J按打印机的纵向移动单位走纸，大多数203dpi打印机的单位与点距相同
+func EscPosFeed(rows int) []byte {
+	var result []byte
+	for ; rows > 0; rows -= 255 {
+		result = append(result, 0x1B, 'J', byte(min(rows, 255)))
+	}
+	return result
+}
+
+// starFeed 在Star光栅模式中使用 ESC * r Y n NUL 纵向移动rows点，n为十进制ASCII，单条指令最多255点
+func starFeed(rows int) []byte {
+	var result []byte
+	for ; rows > 0; rows -= 255 {
+		result = append(result, 0x1B, '*', 'r', 'Y')
+		result = strconv.AppendInt(result, int64(min(rows, 255)), 10)
+		result = append(result, 0x00)
+	}
+	return result
+}
@@ -72,14 +72,20 @@ func (img *RasterImage) CutPages() []*RasterImage {
 		}
 		end := cutLines[line]
 		if end > start {
-			pages = append(pages, img.SelectRows(start, end).Copy())
+			page := img.SelectRows(start, end).Copy()
+			page.Color = img.Color
+			page.Bands = img.bandsIn(start, end)
+			pages = append(pages, page)
 		}
 	}
 
 	// 处理最后一段（如果最后一行不是cutline）
 	lastCut := cutLines[len(cutLines)-1] + 1
 	if lastCut < img.Height {
-		pages = append(pages, img.SelectRows(lastCut, img.Height).Copy())
+		page := img.SelectRows(lastCut, img.Height).Copy()
+		page.Color = img.Color
+		page.Bands = img.bandsIn(lastCut, img.Height)
+		pages = append(pages, page)
 	}
 
 	return pages
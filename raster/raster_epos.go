@@ -5,42 +5,143 @@ import (
 	"encoding/xml"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
-// NewRasterImage 从XML数据中解析并返回RasterImage对象
+// NewRasterImage 从XML数据中解析并返回RasterImage对象，
+// 多张图片会按文档顺序合并为一张图片，<cut>转换为切割线
 func NewRasterImageFromXML(payload []byte) (*RasterImage, error) {
+	eposPrint, err := NewEposPrintFromXML(payload)
+	if err != nil {
+		return nil, err
+	}
+	img := eposPrint.ToRasterImage(0)
+	if img == nil {
+		return nil, fmt.Errorf("no image found in ePOS document")
+	}
+	return img, nil
+}
+
+// NewEposPrintFromXML 从SOAP请求中解析完整的ePOS-Print文档
+func NewEposPrintFromXML(payload []byte) (*EposPrint, error) {
 	envelope := &Envelope{}
 	if err := xml.Unmarshal(payload, envelope); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal SOAP envelope: %w", err)
 	}
-	return envelope.Body.EposPrint.Image, nil
+	eposPrint := &envelope.Body.EposPrint
+	eposPrint.PrintJobID = envelope.Header.Parameter.PrintJobID
+	eposPrint.Timeout = envelope.Header.Parameter.Timeout
+	return eposPrint, nil
 }
 
 // Envelope 表示SOAP信封结构
 type Envelope struct {
 	XMLName xml.Name `xml:"Envelope"`
+	Header  Header   `xml:"Header"`
 	Body    Body     `xml:"Body"`
 }
 
+// Header 表示SOAP消息头，包含设备ID、超时和打印任务ID等参数
+type Header struct {
+	Parameter struct {
+		DevID      string `xml:"devid"`
+		Timeout    int    `xml:"timeout"`
+		PrintJobID string `xml:"printjobid"`
+	} `xml:"parameter"`
+}
+
 // Body 表示SOAP消息体
 type Body struct {
 	XMLName   xml.Name  `xml:"Body"`
 	EposPrint EposPrint `xml:"epos-print"`
 }
 
-// EposPrint 表示打印机指令容器
+// EposPrint 表示打印机指令容器，Commands按文档顺序保存所有指令
 type EposPrint struct {
-	XMLName xml.Name     `xml:"epos-print"`
-	Xmlns   string       `xml:"xmlns,attr"`
-	Image   *RasterImage `xml:"image"`
+	XMLName    xml.Name
+	Xmlns      string
+	Commands   []EposCommand
+	PrintJobID string // 客户端指定的打印任务ID，需要在响应中原样返回
+	Timeout    int    // 客户端等待打印结果的时间（毫秒），0为没有指定
+}
+
+func (p *EposPrint) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	p.XMLName = start.Name
+	p.Xmlns = start.Name.Space
+	commands, err := decodeEposCommands(d, start)
+	if err != nil {
+		return err
+	}
+	p.Commands = commands
+	return nil
+}
+
+// Images 返回文档中的所有图片
+func (p *EposPrint) Images() []*RasterImage {
+	var images []*RasterImage
+	for _, cmd := range p.Commands {
+		if img, ok := cmd.(*RasterImage); ok {
+			images = append(images, img)
+		}
+	}
+	return images
+}
+
+// IsPulseOnly 文档是否只包含打开钱箱的指令
+func (p *EposPrint) IsPulseOnly() bool {
+	if len(p.Commands) == 0 {
+		return false
+	}
+	for _, cmd := range p.Commands {
+		if _, ok := cmd.(*EposPulse); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRasterOnly 文档是否只包含图片（以及走纸、切纸），
+// 这种文档（如Odoo的小票）走光栅打印流程，以便使用打印机的转换器和切纸设置
+func (p *EposPrint) IsRasterOnly() bool {
+	images := 0
+	for _, cmd := range p.Commands {
+		switch cmd.(type) {
+		case *RasterImage:
+			images++
+		case *EposCut, *EposFeed:
+		default:
+			return false
+		}
+	}
+	return images > 0
 }
 
+// ePOS图片的尺寸上限，请求中的宽度和高度决定分配的内存，gray16转换时每个像素需要约9字节
+const (
+	maxImageWidth  = 4096     // 最大宽度（点）
+	maxImageHeight = 65535    // 最大高度（点）
+	maxImagePixels = 16 << 20 // 最大像素数
+)
+
+// checkImageSize 检查请求中图片的宽度和高度，不能为0或负数，也不能超过上限
+func checkImageSize(width, height int) error {
+	if width <= 0 || height <= 0 || width > maxImageWidth || height > maxImageHeight || width*height > maxImagePixels {
+		return fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	return nil
+}
+
+// UnmarshalXML 解析<image>元素，支持mono和gray16两种模式，
+// gray16按halftone和brightness属性转换为二值图像
 func (img *RasterImage) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	type Alias RasterImage
 	aux := &struct {
-		Width   string `xml:"width,attr"`
-		Height  string `xml:"height,attr"`
-		Content string `xml:",chardata"`
+		Width      string `xml:"width,attr"`
+		Height     string `xml:"height,attr"`
+		Mode       string `xml:"mode,attr"`
+		Halftone   string `xml:"halftone,attr"`
+		Brightness string `xml:"brightness,attr"`
+		Content    string `xml:",chardata"`
 		*Alias
 	}{
 		Alias: (*Alias)(img),
@@ -50,21 +151,123 @@ func (img *RasterImage) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 		return err
 	}
 
-	var err error
-	img.Width, err = strconv.Atoi(aux.Width)
+	width, err := strconv.Atoi(aux.Width)
 	if err != nil {
 		return err
 	}
-
 	img.Height, err = strconv.Atoi(aux.Height)
 	if err != nil {
 		return err
 	}
+	if err := checkImageSize(width, img.Height); err != nil {
+		return err
+	}
 
-	img.Content, err = base64.StdEncoding.DecodeString(aux.Content)
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(aux.Content))
 	if err != nil {
 		return err
 	}
 
+	switch strings.ToLower(aux.Mode) {
+	case "gray16":
+		// 每个像素4位，每行按字节对齐
+		if expected := (width + 1) / 2 * img.Height; len(content) < expected {
+			return fmt.Errorf("image data too short: %d bytes, %dx%d gray16 needs %d", len(content), width, img.Height, expected)
+		}
+		brightness, err := strconv.ParseFloat(aux.Brightness, 64)
+		if err != nil {
+			brightness = 1.0
+		}
+		gray := decodeGray16(width, img.Height, content)
+		mono := NewRasterImageFromGray(gray, aux.Halftone, brightness)
+		img.Width = mono.Width
+		img.Content = mono.Content
+	default:
+		// mono模式每行按字节对齐，宽度向上取整到8的倍数
+		img.Width = (width + 7) &^ 7
+		expected := img.Width / 8 * img.Height
+		if len(content) < expected {
+			return fmt.Errorf("image data too short: %d bytes, %dx%d mono needs %d", len(content), width, img.Height, expected)
+		}
+		img.Content = content[:expected] // 多余的数据不打印，否则合并后后面的图片会错位
+	}
+
 	return nil
 }
+
+// ToRasterImage 将只包含图片、走纸和切纸的文档合并为一张图片。
+// 每张图片按自己的align属性在paperWidth（为0时取最宽图片的宽度）内对齐，
+// 文档中间的<cut>转换为切割线，打印时由CutPages拆分为多页分别切纸。
+// 图片的颜色不同时记录在Bands中，双色打印机按每张图片的颜色打印。
+// 只有一张图片时原样返回，保证转换器的图案识别坐标不变。
+func (p *EposPrint) ToRasterImage(paperWidth int) *RasterImage {
+	images := p.Images()
+	if len(images) == 0 {
+		return nil
+	}
+	if len(images) == 1 {
+		return images[0]
+	}
+
+	width := paperWidth
+	for _, img := range images {
+		width = max(width, img.Width)
+	}
+	width = (width + 7) &^ 7
+	result := NewRasterImage(width, 0)
+	result.Align = "left"
+	result.Color = images[0].Color
+	mixed := false // 颜色不一致时每张图片按自己的颜色打印
+	for _, img := range images {
+		mixed = mixed || img.Color != result.Color
+	}
+	if mixed {
+		result.Color = ""
+	}
+
+	pending := false // 是否有未输出的切纸
+	for _, cmd := range p.Commands {
+		var block *RasterImage
+		switch c := cmd.(type) {
+		case *RasterImage:
+			block = &RasterImage{Width: c.Width, Height: c.Height, Align: c.Align, Content: append([]byte(nil), c.Content...)}
+			block.AutoMarginLeft(width)
+			block.AddMarginRight(width - block.Width)
+		case *EposFeed:
+			block = NewRasterImage(width, c.feedDots())
+		case *EposCut:
+			pending = result.Height > 0
+			continue
+		}
+		if block == nil || block.Height <= 0 {
+			continue
+		}
+		if pending {
+			cutline := make([]byte, width/8)
+			copy(cutline, CUTLINE)
+			result.Content = append(result.Content, cutline...)
+			result.Height++
+			pending = false
+		}
+		if c, ok := cmd.(*RasterImage); ok && mixed {
+			result.Bands = append(result.Bands, ColorBand{Y: result.Height, Height: block.Height, Color: c.Color})
+		}
+		result.Content = append(result.Content, block.Content...)
+		result.Height += block.Height
+	}
+	return result
+}
+
+// feedDots 返回走纸指令对应的点数，按每行30点计算
+func (f *EposFeed) feedDots() int {
+	switch {
+	case f.Unit != "":
+		return atoiDefault(f.Unit, 0)
+	case f.Line != "":
+		return atoiDefault(f.Line, 1) * 30
+	case f.Pos != "":
+		return 0
+	default:
+		return 30
+	}
+}
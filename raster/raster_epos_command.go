@@ -0,0 +1,241 @@
+package raster
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// EposCommand 表示ePOS-Print文档中的一条指令，按文档顺序转换为ESC/POS字节流
+type EposCommand interface {
+	writeEscPos(w *escPosWriter)
+}
+
+// eposCommandFactories 元素名到指令对象的映射，未知元素会被忽略
+var eposCommandFactories = map[string]func() EposCommand{
+	"text":        func() EposCommand { return &EposText{} },
+	"feed":        func() EposCommand { return &EposFeed{} },
+	"cut":         func() EposCommand { return &EposCut{} },
+	"image":       func() EposCommand { return &RasterImage{} },
+	"logo":        func() EposCommand { return &EposLogo{} },
+	"barcode":     func() EposCommand { return &EposBarcode{} },
+	"symbol":      func() EposCommand { return &EposSymbol{} },
+	"hline":       func() EposCommand { return &EposHLine{} },
+	"vline-begin": func() EposCommand { return &EposVLineBegin{} },
+	"vline-end":   func() EposCommand { return &EposVLineEnd{} },
+	"page":        func() EposCommand { return &EposPage{} },
+	"area":        func() EposCommand { return &EposArea{} },
+	"direction":   func() EposCommand { return &EposDirection{} },
+	"position":    func() EposCommand { return &EposPosition{} },
+	"line":        func() EposCommand { return &EposLine{} },
+	"rectangle":   func() EposCommand { return &EposRectangle{} },
+	"sound":       func() EposCommand { return &EposSound{} },
+	"layout":      func() EposCommand { return &EposLayout{} },
+	"pulse":       func() EposCommand { return &EposPulse{} },
+	"command":     func() EposCommand { return &EposRawCommand{} },
+	"reset":       func() EposCommand { return &EposReset{} },
+}
+
+// decodeEposCommands 按顺序解析start元素下的所有子指令，直到遇到对应的结束标签
+func decodeEposCommands(d *xml.Decoder, start xml.StartElement) ([]EposCommand, error) {
+	var commands []EposCommand
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			factory, ok := eposCommandFactories[t.Name.Local]
+			if !ok {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			cmd := factory()
+			if err := d.DecodeElement(cmd, &t); err != nil {
+				return nil, err
+			}
+			commands = append(commands, cmd)
+		case xml.EndElement:
+			if t.Name.Local == start.Name.Local {
+				return commands, nil
+			}
+		}
+	}
+}
+
+// EposText 表示<text>元素，属性为空时沿用打印机当前的设置
+type EposText struct {
+	Lang    string `xml:"lang,attr"`
+	Font    string `xml:"font,attr"`
+	Smooth  string `xml:"smooth,attr"`
+	Width   string `xml:"width,attr"`
+	Height  string `xml:"height,attr"`
+	DW      string `xml:"dw,attr"`
+	DH      string `xml:"dh,attr"`
+	Em      string `xml:"em,attr"`
+	Ul      string `xml:"ul,attr"`
+	Reverse string `xml:"reverse,attr"`
+	Color   string `xml:"color,attr"`
+	Align   string `xml:"align,attr"`
+	X       string `xml:"x,attr"`
+	LineSpc string `xml:"linespc,attr"`
+	Rotate  string `xml:"rotate,attr"`
+	Content string `xml:",chardata"`
+}
+
+// EposFeed 表示<feed>元素，unit按点走纸，line按行走纸，pos走纸到指定位置
+type EposFeed struct {
+	Unit string `xml:"unit,attr"`
+	Line string `xml:"line,attr"`
+	Pos  string `xml:"pos,attr"`
+}
+
+// EposCut 表示<cut>元素
+type EposCut struct {
+	Type string `xml:"type,attr"`
+}
+
+// EposLogo 表示<logo>元素，打印存储在NV内存中的图片
+type EposLogo struct {
+	Key1 string `xml:"key1,attr"`
+	Key2 string `xml:"key2,attr"`
+}
+
+// EposBarcode 表示<barcode>元素
+type EposBarcode struct {
+	Type    string `xml:"type,attr"`
+	Hri     string `xml:"hri,attr"`
+	Font    string `xml:"font,attr"`
+	Width   string `xml:"width,attr"`
+	Height  string `xml:"height,attr"`
+	Rotate  string `xml:"rotate,attr"`
+	Content string `xml:",chardata"`
+}
+
+// EposSymbol 表示<symbol>元素（二维码、PDF417等）
+type EposSymbol struct {
+	Type    string `xml:"type,attr"`
+	Level   string `xml:"level,attr"`
+	Width   string `xml:"width,attr"`
+	Height  string `xml:"height,attr"`
+	Size    string `xml:"size,attr"`
+	Content string `xml:",chardata"`
+}
+
+// EposHLine 表示<hline>元素，水平线
+type EposHLine struct {
+	X1    string `xml:"x1,attr"`
+	X2    string `xml:"x2,attr"`
+	Style string `xml:"style,attr"`
+}
+
+// EposVLineBegin 表示<vline-begin>元素
+type EposVLineBegin struct {
+	X     string `xml:"x,attr"`
+	Style string `xml:"style,attr"`
+}
+
+// EposVLineEnd 表示<vline-end>元素
+type EposVLineEnd struct {
+	X     string `xml:"x,attr"`
+	Style string `xml:"style,attr"`
+}
+
+// EposPage 表示<page>元素，子指令在页模式下执行
+type EposPage struct {
+	Commands []EposCommand
+}
+
+func (p *EposPage) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	commands, err := decodeEposCommands(d, start)
+	if err != nil {
+		return err
+	}
+	p.Commands = commands
+	return nil
+}
+
+// EposArea 表示页模式中的<area>元素，设置打印区域
+type EposArea struct {
+	X      string `xml:"x,attr"`
+	Y      string `xml:"y,attr"`
+	Width  string `xml:"width,attr"`
+	Height string `xml:"height,attr"`
+}
+
+// EposDirection 表示页模式中的<direction>元素，设置打印方向
+type EposDirection struct {
+	Dir string `xml:"dir,attr"`
+}
+
+// EposPosition 表示页模式中的<position>元素，设置打印位置
+type EposPosition struct {
+	X string `xml:"x,attr"`
+	Y string `xml:"y,attr"`
+}
+
+// EposLine 表示页模式中的<line>元素
+type EposLine struct {
+	X1    string `xml:"x1,attr"`
+	Y1    string `xml:"y1,attr"`
+	X2    string `xml:"x2,attr"`
+	Y2    string `xml:"y2,attr"`
+	Style string `xml:"style,attr"`
+}
+
+// EposRectangle 表示页模式中的<rectangle>元素
+type EposRectangle struct {
+	X1    string `xml:"x1,attr"`
+	Y1    string `xml:"y1,attr"`
+	X2    string `xml:"x2,attr"`
+	Y2    string `xml:"y2,attr"`
+	Style string `xml:"style,attr"`
+}
+
+// EposSound 表示<sound>元素，蜂鸣器
+type EposSound struct {
+	Pattern string `xml:"pattern,attr"`
+	Repeat  string `xml:"repeat,attr"`
+	Cycle   string `xml:"cycle,attr"`
+}
+
+// EposLayout 表示<layout>元素，设置纸张布局（单位0.1mm）
+type EposLayout struct {
+	Type         string `xml:"type,attr"`
+	Width        string `xml:"width,attr"`
+	Height       string `xml:"height,attr"`
+	MarginTop    string `xml:"margin-top,attr"`
+	MarginBottom string `xml:"margin-bottom,attr"`
+	OffsetCut    string `xml:"offset-cut,attr"`
+	OffsetLabel  string `xml:"offset-label,attr"`
+}
+
+// EposPulse 表示<pulse>元素，打开钱箱
+type EposPulse struct {
+	Drawer string `xml:"drawer,attr"`
+	Time   string `xml:"time,attr"`
+}
+
+// EposRawCommand 表示<command>元素，内容为十六进制的原始指令
+type EposRawCommand struct {
+	Content string `xml:",chardata"`
+}
+
+// EposReset 表示<reset>元素，初始化打印机
+type EposReset struct{}
+
+// atoiDefault 解析整数属性，属性为空或非法时返回默认值
+func atoiDefault(s string, def int) int {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// clamp 将数值限制在[lo, hi]范围内
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,500 @@
+package raster
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+const (
+	escposESC = 0x1B
+	escposGS  = 0x1D
+	escposFS  = 0x1C
+	escposLF  = 0x0A
+	escposFF  = 0x0C
+)
+
+// escPosWriter 保存转换过程中的打印机状态（编码、对齐方式）
+type escPosWriter struct {
+	bytes.Buffer
+	encoder *encoding.Encoder // 当前文本编码，nil表示按原样输出
+	align   byte              // 当前对齐方式，输出图片后需要恢复
+	width   int               // 纸张宽度（点），横线不超出纸张
+}
+
+func (w *escPosWriter) command(b ...byte) {
+	w.Write(b)
+}
+
+func (w *escPosWriter) setAlign(align string) {
+	switch strings.ToLower(align) {
+	case "left":
+		w.align = 0
+	case "center":
+		w.align = 1
+	case "right":
+		w.align = 2
+	default:
+		return
+	}
+	w.command(escposESC, 'a', w.align)
+}
+
+// writeRaster 以指定的对齐方式输出光栅图像，输出后恢复文本的对齐方式
+func (w *escPosWriter) writeRaster(img *RasterImage, align string) {
+	saved := w.align
+	w.setAlign(align)
+	if img.IsColor() {
+		w.Write(img.ToEscPosColorRasterCommand())
+	} else {
+		w.Write(img.ToEscPosRasterCommand(1024))
+	}
+	if w.align != saved {
+		w.align = saved
+		w.command(escposESC, 'a', saved)
+	}
+}
+
+// ToEscPosCommand 将整个ePOS-Print文档按顺序转换为一个ESC/POS字节流，paperWidth<=0时使用576
+func (p *EposPrint) ToEscPosCommand(paperWidth int) []byte {
+	if paperWidth <= 0 {
+		paperWidth = 576
+	}
+	w := &escPosWriter{width: paperWidth}
+	w.command(escposESC, '@') // 初始化打印机，避免上一个任务的格式残留
+	for _, cmd := range p.Commands {
+		cmd.writeEscPos(w)
+	}
+	return w.Bytes()
+}
+
+// setLang 选择语言对应的代码页或汉字模式
+func (w *escPosWriter) setLang(lang string) {
+	switch strings.ToLower(lang) {
+	case "ja":
+		w.encoder = japanese.ShiftJIS.NewEncoder()
+		w.command(escposFS, 'C', 1)  // Shift JIS
+		w.command(escposFS, '&')     // 进入汉字模式
+		w.command(escposESC, 't', 1) // Katakana
+	case "zh-cn":
+		w.encoder = simplifiedchinese.GB18030.NewEncoder()
+		w.command(escposFS, '&')
+	case "zh-tw":
+		w.encoder = traditionalchinese.Big5.NewEncoder()
+		w.command(escposFS, '&')
+	case "ko":
+		w.encoder = korean.EUCKR.NewEncoder()
+		w.command(escposFS, '&')
+	case "th":
+		w.encoder = charmap.Windows874.NewEncoder()
+		w.command(escposFS, '.')      // 退出汉字模式
+		w.command(escposESC, 't', 21) // Thai Character Code 11
+	case "vi":
+		w.encoder = charmap.Windows1258.NewEncoder()
+		w.command(escposFS, '.')
+		w.command(escposESC, 't', 52) // WPC1258
+	case "":
+		return
+	default:
+		w.encoder = charmap.Windows1252.NewEncoder()
+		w.command(escposFS, '.')
+		w.command(escposESC, 't', 16) // WPC1252
+	}
+}
+
+func boolAttr(s string) (value byte, ok bool) {
+	switch strings.ToLower(s) {
+	case "true":
+		return 1, true
+	case "false":
+		return 0, true
+	}
+	return 0, false
+}
+
+func (t *EposText) writeEscPos(w *escPosWriter) {
+	if t.Lang != "" {
+		w.setLang(t.Lang)
+	}
+	if t.Font != "" {
+		fonts := map[string]byte{"font_a": 0, "font_b": 1, "font_c": 2, "font_d": 3, "font_e": 4, "special_a": 97, "special_b": 98}
+		if n, ok := fonts[strings.ToLower(t.Font)]; ok {
+			w.command(escposESC, 'M', n)
+		}
+	}
+	if v, ok := boolAttr(t.Smooth); ok {
+		w.command(escposGS, 'b', v)
+	}
+	width, height := atoiDefault(t.Width, 0), atoiDefault(t.Height, 0)
+	if v, ok := boolAttr(t.DW); ok {
+		width = 1 + int(v)
+	}
+	if v, ok := boolAttr(t.DH); ok {
+		height = 1 + int(v)
+	}
+	if width > 0 || height > 0 {
+		width, height = clamp(max(width, 1), 1, 8), clamp(max(height, 1), 1, 8)
+		w.command(escposGS, '!', byte((width-1)<<4|(height-1)))
+	}
+	if v, ok := boolAttr(t.Em); ok {
+		w.command(escposESC, 'E', v)
+	}
+	if v, ok := boolAttr(t.Ul); ok {
+		w.command(escposESC, '-', v)
+	}
+	if v, ok := boolAttr(t.Reverse); ok {
+		w.command(escposGS, 'B', v)
+	}
+	switch strings.ToLower(t.Color) {
+	case "color_1":
+		w.command(escposESC, 'r', 0)
+	case "color_2", "color_3", "color_4":
+		w.command(escposESC, 'r', 1)
+	}
+	if v, ok := boolAttr(t.Rotate); ok {
+		w.command(escposESC, 'V', v)
+	}
+	if t.LineSpc != "" {
+		w.command(escposESC, '3', byte(clamp(atoiDefault(t.LineSpc, 30), 0, 255)))
+	}
+	if t.Align != "" {
+		w.setAlign(t.Align)
+	}
+	if t.X != "" {
+		xL, xH := LowHighValue(atoiDefault(t.X, 0))
+		w.command(escposESC, '$', xL, xH)
+	}
+	if t.Content == "" {
+		return
+	}
+	content := []byte(t.Content)
+	if w.encoder != nil {
+		if encoded, err := w.encoder.Bytes(content); err == nil {
+			content = encoded
+		}
+	}
+	w.Write(content)
+}
+
+func (f *EposFeed) writeEscPos(w *escPosWriter) {
+	switch {
+	case f.Unit != "":
+		for unit := atoiDefault(f.Unit, 0); unit > 0; unit -= 255 {
+			w.command(escposESC, 'J', byte(min(unit, 255)))
+		}
+	case f.Line != "":
+		w.command(escposESC, 'd', byte(clamp(atoiDefault(f.Line, 1), 0, 255)))
+	case f.Pos != "":
+		switch strings.ToLower(f.Pos) {
+		case "peeling":
+			w.command(escposFS, '(', 'L', 2, 0, 65, 48)
+		case "cutting":
+			w.command(escposFS, '(', 'L', 2, 0, 66, 48)
+		case "current_tof":
+			w.command(escposFS, '(', 'L', 2, 0, 67, 48)
+		case "next_tof":
+			w.command(escposFS, '(', 'L', 2, 0, 67, 50)
+		}
+	default:
+		w.command(escposLF)
+	}
+}
+
+func (c *EposCut) writeEscPos(w *escPosWriter) {
+	switch strings.ToLower(c.Type) {
+	case "no_feed":
+		w.command(escposGS, 'V', 1)
+	case "reserve":
+		w.command(escposGS, 'V', 104, 0)
+	case "full_cut_feed":
+		w.command(escposGS, 'V', 65, 0)
+	case "full_cut_no_feed":
+		w.command(escposGS, 'V', 0)
+	case "full_cut_reserve":
+		w.command(escposGS, 'V', 103, 0)
+	default: // feed
+		w.command(escposGS, 'V', 66, 0)
+	}
+}
+
+func (img *RasterImage) writeEscPos(w *escPosWriter) {
+	if img.Width <= 0 || img.Height <= 0 || len(img.Content) == 0 {
+		return
+	}
+	w.writeRaster(img, img.Align)
+}
+
+func (l *EposLogo) writeEscPos(w *escPosWriter) {
+	kc1 := byte(clamp(atoiDefault(l.Key1, 32), 32, 126))
+	kc2 := byte(clamp(atoiDefault(l.Key2, 32), 32, 126))
+	w.command(escposGS, '(', 'L', 6, 0, 48, 69, kc1, kc2, 1, 1)
+}
+
+func (b *EposBarcode) writeEscPos(w *escPosWriter) {
+	types := map[string]byte{
+		"upc_a": 65, "upc_e": 66, "ean13": 67, "jan13": 67, "ean8": 68, "jan8": 68,
+		"code39": 69, "itf": 70, "codabar": 71, "code93": 72, "code128": 73, "gs1_128": 74,
+		"gs1_databar_omnidirectional": 75, "gs1_databar_truncated": 76,
+		"gs1_databar_limited": 77, "gs1_databar_expanded": 78,
+	}
+	m, ok := types[strings.ToLower(b.Type)]
+	if !ok {
+		return
+	}
+	hri := map[string]byte{"none": 0, "above": 1, "below": 2, "both": 3}
+	if n, ok := hri[strings.ToLower(b.Hri)]; ok {
+		w.command(escposGS, 'H', n)
+	}
+	if strings.ToLower(b.Font) == "font_b" {
+		w.command(escposGS, 'f', 1)
+	} else if b.Font != "" {
+		w.command(escposGS, 'f', 0)
+	}
+	if b.Width != "" {
+		w.command(escposGS, 'w', byte(clamp(atoiDefault(b.Width, 3), 2, 6)))
+	}
+	if b.Height != "" {
+		w.command(escposGS, 'h', byte(clamp(atoiDefault(b.Height, 162), 1, 255)))
+	}
+	data := []byte(b.Content)
+	if m == 73 && !bytes.HasPrefix(data, []byte("{")) {
+		data = append([]byte("{B"), data...) // CODE128默认使用字符集B
+	}
+	if len(data) == 0 || len(data) > 255 {
+		return
+	}
+	w.command(escposGS, 'k', m, byte(len(data)))
+	w.Write(data)
+}
+
+// symbolFunction 输出 GS ( k 指令
+func (w *escPosWriter) symbolFunction(cn, fn byte, params ...byte) {
+	pL, pH := LowHighValue(len(params) + 2)
+	w.command(escposGS, '(', 'k', pL, pH, cn, fn)
+	w.Write(params)
+}
+
+func (s *EposSymbol) writeEscPos(w *escPosWriter) {
+	data := []byte(s.Content)
+	if len(data) == 0 {
+		return
+	}
+	symbolType := strings.ToLower(s.Type)
+	level := strings.ToLower(s.Level)
+	width := atoiDefault(s.Width, 3)
+	var cn byte
+	switch {
+	case strings.HasPrefix(symbolType, "qrcode"):
+		cn = 49
+		model := byte(50)
+		switch symbolType {
+		case "qrcode_model_1":
+			model = 49
+		case "qrcode_micro":
+			model = 51
+		}
+		w.symbolFunction(cn, 65, model, 0)
+		w.symbolFunction(cn, 67, byte(clamp(width, 1, 16)))
+		levels := map[string]byte{"level_l": 48, "level_m": 49, "level_q": 50, "level_h": 51}
+		if n, ok := levels[level]; ok {
+			w.symbolFunction(cn, 69, n)
+		}
+	case strings.HasPrefix(symbolType, "pdf417"):
+		cn = 48
+		w.symbolFunction(cn, 65, byte(clamp(atoiDefault(s.Size, 0), 0, 30)))
+		w.symbolFunction(cn, 67, byte(clamp(width, 2, 8)))
+		w.symbolFunction(cn, 68, byte(clamp(atoiDefault(s.Height, 3), 2, 8)))
+		if strings.HasPrefix(level, "level_") {
+			if n, err := strconv.Atoi(strings.TrimPrefix(level, "level_")); err == nil {
+				w.symbolFunction(cn, 69, 48, byte(48+clamp(n, 0, 8)))
+			}
+		}
+		if symbolType == "pdf417_truncated" {
+			w.symbolFunction(cn, 70, 1)
+		} else {
+			w.symbolFunction(cn, 70, 0)
+		}
+	case strings.HasPrefix(symbolType, "maxicode"):
+		cn = 50
+		mode := atoiDefault(strings.TrimPrefix(symbolType, "maxicode_mode_"), 2)
+		w.symbolFunction(cn, 65, byte(48+clamp(mode, 2, 6)))
+	case strings.HasPrefix(symbolType, "azteccode"):
+		cn = 53
+		mode := byte(0)
+		if symbolType == "azteccode_compact" {
+			mode = 1
+		}
+		w.symbolFunction(cn, 66, mode, 0)
+		w.symbolFunction(cn, 67, byte(clamp(width, 2, 16)))
+	case strings.HasPrefix(symbolType, "datamatrix"):
+		cn = 54
+		if symbolType == "datamatrix_square" {
+			w.symbolFunction(cn, 66, 0, 0, 0)
+		} else {
+			rows := byte(atoiDefault(strings.TrimPrefix(symbolType, "datamatrix_rectangle_"), 8))
+			w.symbolFunction(cn, 66, 1, rows, 0)
+		}
+		w.symbolFunction(cn, 67, byte(clamp(width, 2, 16)))
+	default:
+		return // 不支持的符号类型（如GS1 DataBar二维码）
+	}
+	w.symbolFunction(cn, 80, append([]byte{48}, data...)...) // 存储数据
+	w.symbolFunction(cn, 81, 48)                             // 打印符号
+}
+
+// lineThickness 返回线型对应的线宽（点）以及是否为双线
+func lineThickness(style string) (int, bool) {
+	style = strings.ToLower(style)
+	double := strings.HasSuffix(style, "_double")
+	switch strings.TrimSuffix(style, "_double") {
+	case "medium":
+		return 2, double
+	case "thick":
+		return 3, double
+	default:
+		return 1, double
+	}
+}
+
+// hline在标准模式下没有对应的ESC/POS指令，使用光栅图像绘制
+func (l *EposHLine) writeEscPos(w *escPosWriter) {
+	x1, x2 := atoiDefault(l.X1, 0), atoiDefault(l.X2, 0)
+	x2 = min(x2, w.width-1) // 超出纸张的部分不打印
+	if x1 < 0 || x2 <= x1 {
+		return
+	}
+	thickness, double := lineThickness(l.Style)
+	height := thickness
+	if double {
+		height = thickness*2 + 2
+	}
+	img := NewRasterImage(x2+1, height)
+	for y := range height {
+		if double && y >= thickness && y < thickness+2 {
+			continue // 双线之间的间隔
+		}
+		for x := x1; x <= x2; x++ {
+			img.SetPixelBlack(x, y)
+		}
+	}
+	w.writeRaster(img, "left")
+}
+
+// 标准模式下无法在文本流中绘制竖线，vline-begin/vline-end仅为兼容而解析
+func (l *EposVLineBegin) writeEscPos(w *escPosWriter) {}
+
+func (l *EposVLineEnd) writeEscPos(w *escPosWriter) {}
+
+func (p *EposPage) writeEscPos(w *escPosWriter) {
+	w.command(escposESC, 'L') // 进入页模式
+	for _, cmd := range p.Commands {
+		cmd.writeEscPos(w)
+	}
+	w.command(escposFF) // 打印并返回标准模式
+}
+
+func (a *EposArea) writeEscPos(w *escPosWriter) {
+	xL, xH := LowHighValue(atoiDefault(a.X, 0))
+	yL, yH := LowHighValue(atoiDefault(a.Y, 0))
+	dxL, dxH := LowHighValue(atoiDefault(a.Width, 576))
+	dyL, dyH := LowHighValue(atoiDefault(a.Height, 0))
+	w.command(escposESC, 'W', xL, xH, yL, yH, dxL, dxH, dyL, dyH)
+}
+
+func (d *EposDirection) writeEscPos(w *escPosWriter) {
+	dirs := map[string]byte{"left_to_right": 0, "bottom_to_top": 1, "right_to_left": 2, "top_to_bottom": 3}
+	if n, ok := dirs[strings.ToLower(d.Dir)]; ok {
+		w.command(escposESC, 'T', n)
+	}
+}
+
+func (p *EposPosition) writeEscPos(w *escPosWriter) {
+	if p.X != "" {
+		xL, xH := LowHighValue(atoiDefault(p.X, 0))
+		w.command(escposESC, '$', xL, xH)
+	}
+	if p.Y != "" {
+		yL, yH := LowHighValue(atoiDefault(p.Y, 0))
+		w.command(escposGS, '$', yL, yH)
+	}
+}
+
+// pageShape 输出页模式下的画线/画框指令 GS ( Q
+func (w *escPosWriter) pageShape(fn byte, x1, y1, x2, y2, style string) {
+	thickness, double := lineThickness(style)
+	m := byte(thickness)
+	if double {
+		m += 16
+	}
+	x1L, x1H := LowHighValue(atoiDefault(x1, 0))
+	y1L, y1H := LowHighValue(atoiDefault(y1, 0))
+	x2L, x2H := LowHighValue(atoiDefault(x2, 0))
+	y2L, y2H := LowHighValue(atoiDefault(y2, 0))
+	w.command(escposGS, '(', 'Q', 12, 0, fn, x1L, x1H, y1L, y1H, x2L, x2H, y2L, y2H, m, 1, 0)
+}
+
+func (l *EposLine) writeEscPos(w *escPosWriter) {
+	w.pageShape(48, l.X1, l.Y1, l.X2, l.Y2, l.Style)
+}
+
+func (r *EposRectangle) writeEscPos(w *escPosWriter) {
+	w.pageShape(49, r.X1, r.Y1, r.X2, r.Y2, r.Style)
+}
+
+// 使用兼容机通用的 ESC B n t 蜂鸣指令，t的单位为50ms
+func (s *EposSound) writeEscPos(w *escPosWriter) {
+	if strings.ToLower(s.Pattern) == "none" {
+		return
+	}
+	repeat := clamp(atoiDefault(s.Repeat, 1), 1, 9)
+	cycle := clamp(atoiDefault(s.Cycle, 100)/50, 1, 9)
+	w.command(escposESC, 'B', byte(repeat), byte(cycle))
+}
+
+// 使用 FS ( L <功能33> 设置纸张布局，参数为十进制ASCII并以分号分隔
+func (l *EposLayout) writeEscPos(w *escPosWriter) {
+	types := map[string]byte{"receipt": 48, "receipt_bm": 49, "label": 50, "label_bm": 51}
+	m, ok := types[strings.ToLower(l.Type)]
+	if !ok {
+		return
+	}
+	params := []byte{33, m}
+	for _, v := range []string{l.Width, l.Height, l.MarginTop, l.MarginBottom, l.OffsetCut, l.OffsetLabel} {
+		params = append(params, []byte(v)...)
+		params = append(params, ';')
+	}
+	pL, pH := LowHighValue(len(params))
+	w.command(escposFS, '(', 'L', pL, pH)
+	w.Write(params)
+}
+
+func (p *EposPulse) writeEscPos(w *escPosWriter) {
+	m := byte(0)
+	if strings.ToLower(p.Drawer) == "drawer_2" {
+		m = 1
+	}
+	ms := atoiDefault(strings.TrimPrefix(strings.ToLower(p.Time), "pulse_"), 100)
+	w.command(escposESC, 'p', m, byte(clamp(ms/2, 1, 255)), 0xFA)
+}
+
+func (c *EposRawCommand) writeEscPos(w *escPosWriter) {
+	data, err := hex.DecodeString(strings.TrimSpace(c.Content))
+	if err != nil {
+		return
+	}
+	w.Write(data)
+}
+
+func (r *EposReset) writeEscPos(w *escPosWriter) {
+	w.command(escposESC, '@')
+	w.encoder = nil
+	w.align = 0
+}
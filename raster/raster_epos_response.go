@@ -0,0 +1,90 @@
+package raster
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// ePOS-Print 响应中的状态位（ASB, Automatic Status Back）
+const (
+	ASB_NO_RESPONSE        uint32 = 0x00000001 // 打印机无响应
+	ASB_PRINT_SUCCESS      uint32 = 0x00000002 // 打印完成
+	ASB_DRAWER_KICK        uint32 = 0x00000004 // 钱箱信号
+	ASB_OFF_LINE           uint32 = 0x00000008 // 离线
+	ASB_COVER_OPEN         uint32 = 0x00000020 // 上盖打开
+	ASB_PAPER_FEED         uint32 = 0x00000040 // 正在按走纸键
+	ASB_WAIT_ON_LINE       uint32 = 0x00000100 // 等待恢复在线
+	ASB_PANEL_SWITCH       uint32 = 0x00000200 // 面板按键被按下
+	ASB_MECHANICAL_ERR     uint32 = 0x00000400 // 机械错误
+	ASB_AUTOCUTTER_ERR     uint32 = 0x00000800 // 切刀错误
+	ASB_UNRECOVER_ERR      uint32 = 0x00002000 // 不可恢复错误
+	ASB_AUTORECOVER_ERR    uint32 = 0x00004000 // 可自动恢复错误
+	ASB_RECEIPT_NEAR_END   uint32 = 0x00020000 // 纸将尽
+	ASB_RECEIPT_END        uint32 = 0x00080000 // 缺纸
+	ASB_BUZZER             uint32 = 0x01000000 // 蜂鸣器
+	ASB_SPOOLER_IS_STOPPED uint32 = 0x80000000 // 打印队列已停止
+)
+
+// ePOS-Print 响应中的错误码
+const (
+	EPOS_CODE_AUTOMATICAL      = "EPTR_AUTOMATICAL"
+	EPOS_CODE_COVER_OPEN       = "EPTR_COVER_OPEN"
+	EPOS_CODE_CUTTER           = "EPTR_CUTTER"
+	EPOS_CODE_MECHANICAL       = "EPTR_MECHANICAL"
+	EPOS_CODE_REC_EMPTY        = "EPTR_REC_EMPTY"
+	EPOS_CODE_UNRECOVERABLE    = "EPTR_UNRECOVERABLE"
+	EPOS_CODE_SCHEMA_ERROR     = "SchemaError"
+	EPOS_CODE_DEVICE_NOT_FOUND = "DeviceNotFound"
+	EPOS_CODE_PRINT_SYSTEM     = "PrintSystemError"
+	EPOS_CODE_BADPORT          = "EX_BADPORT"
+	EPOS_CODE_TIMEOUT          = "EX_TIMEOUT"
+	EPOS_CODE_SPOOLER          = "EX_SPOOLER"
+)
+
+const eposPrintNamespace = "http://www.epson-pos.com/schemas/2011/03/epos-print"
+
+// EposResponse 表示ePOS-Print的<response>元素
+type EposResponse struct {
+	XMLName    xml.Name `xml:"response"`
+	Success    bool     `xml:"success,attr"`
+	Code       string   `xml:"code,attr"`
+	Status     uint32   `xml:"status,attr"`
+	Battery    int      `xml:"battery,attr"`
+	Xmlns      string   `xml:"xmlns,attr"`
+	PrintJobID string   `xml:"printjobid,omitempty"`
+}
+
+// NewEposResponse 创建一个响应，success为true时code为空
+func NewEposResponse(success bool, code string, status uint32) *EposResponse {
+	return &EposResponse{
+		Success: success,
+		Code:    code,
+		Status:  status,
+		Xmlns:   eposPrintNamespace,
+	}
+}
+
+func (r *EposResponse) String() string {
+	return "EposResponse(success: " + strconv.FormatBool(r.Success) + ", code: " + r.Code + ", status: " + strconv.FormatUint(uint64(r.Status), 10) + ")"
+}
+
+// ToXML 将响应包装为SOAP信封
+func (r *EposResponse) ToXML() []byte {
+	type responseBody struct {
+		Response *EposResponse
+	}
+	type responseEnvelope struct {
+		XMLName xml.Name     `xml:"s:Envelope"`
+		XmlnsS  string       `xml:"xmlns:s,attr"`
+		Body    responseBody `xml:"s:Body"`
+	}
+	envelope := responseEnvelope{
+		XmlnsS: "http://schemas.xmlsoap.org/soap/envelope/",
+		Body:   responseBody{Response: r},
+	}
+	data, err := xml.Marshal(envelope)
+	if err != nil {
+		return nil
+	}
+	return append([]byte(xml.Header), data...)
+}
@@ -0,0 +1,54 @@
+package raster
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func parseEpos(t *testing.T, body string) *EposPrint {
+	t.Helper()
+	p, err := NewEposPrintFromXML([]byte(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` +
+		`<epos-print xmlns="http://www.epson-pos.com/schemas/2011/03/epos-print">` + body + `</epos-print></s:Body></s:Envelope>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestToRasterImageColors(t *testing.T) {
+	// 红色图片两行，黑色图片一行但数据多了两个字节
+	p := parseEpos(t, `<image width="8" height="2" color="color_2" align="left">//8=</image>`+
+		`<feed unit="4"/><image width="8" height="1" color="color_1" align="left">/wAA</image>`)
+	img := p.ToRasterImage(8)
+	if img.Height != 7 {
+		t.Fatalf("merged image height %d, want 7", img.Height)
+	}
+	if got := img.GetRow(6); !bytes.Equal(got, []byte{0xFF}) {
+		t.Errorf("last row %x, want ff: extra image data must be dropped", got)
+	}
+	want := []ColorBand{{0, 2, "color_2"}, {2, 4, ""}, {6, 1, "color_1"}}
+	if got := img.ColorBands(); !slices.Equal(got, want) {
+		t.Errorf("ColorBands() = %v, want %v", got, want)
+	}
+
+	// 双色打印时红色部分用颜色50，黑色部分用颜色49
+	data := img.ToEscPosColorRasterCommand()
+	red := []byte{0x1D, '(', 'L', 12, 0, 48, 112, 48, 1, 1, 50, 8, 0, 2, 0, 0xFF, 0xFF}
+	black := []byte{0x1D, '(', 'L', 11, 0, 48, 112, 48, 1, 1, 49, 8, 0, 1, 0, 0xFF}
+	if !bytes.Contains(data, red) || !bytes.Contains(data, black) {
+		t.Errorf("color raster %x, want a red and a black block", data)
+	}
+}
+
+func TestCutPagesColors(t *testing.T) {
+	p := parseEpos(t, `<image width="8" height="1" color="color_2">/w==</image><cut/>`+
+		`<image width="8" height="1" color="color_1">/w==</image>`)
+	pages := p.ToRasterImage(8).CutPages()
+	if len(pages) != 2 {
+		t.Fatalf("%d pages, want 2", len(pages))
+	}
+	if !pages[0].IsColor() || pages[1].IsColor() {
+		t.Errorf("pages %v / %v, want the first page red and the second black", pages[0].ColorBands(), pages[1].ColorBands())
+	}
+}
@@ -1,5 +1,7 @@
 package raster
 
+import "strings"
+
 func (img *RasterImage) toGSV0() []byte {
 	// 参数验证
 	if img == nil || img.Width <= 0 || img.Height <= 0 || img.Content == nil {
@@ -46,3 +48,112 @@ func LowHighValue(value int) (low, high byte) {
 	high = byte((value >> 8) & 0xFF)
 	return
 }
+
+// ToEscPosColorRasterCommand 使用 GS ( L <功能112> 按图像颜色输出光栅数据，
+// 适用于支持双色（红/黑）打印的打印机，每段数据存入打印缓冲区后用 <功能50> 打印。
+// 合并了不同颜色图片的图像按ColorBands分段，每段使用自己的颜色
+func (img *RasterImage) ToEscPosColorRasterCommand() []byte {
+	if img == nil || img.Width <= 0 || img.Height <= 0 || img.Content == nil {
+		return nil
+	}
+	colors := map[string]byte{"color_1": 49, "color_2": 50, "color_3": 51, "color_4": 52}
+	const GS = 0x1D
+	widthBytes := img.Width / 8
+	maxRows := min((65535-10)/widthBytes, 1662) // 单条指令的数据长度和高度限制
+	xL, xH := LowHighValue(img.Width)
+	result := make([]byte, 0, 100+len(img.Content))
+	for _, band := range img.ColorBands() {
+		c, ok := colors[strings.ToLower(band.Color)]
+		if !ok {
+			c = 49
+		}
+		for offset := band.Y; offset < band.Y+band.Height; offset += maxRows {
+			rows := min(maxRows, band.Y+band.Height-offset)
+			data := img.Content[offset*widthBytes : (offset+rows)*widthBytes]
+			pL, pH := LowHighValue(10 + len(data))
+			yL, yH := LowHighValue(rows)
+			result = append(result, GS, '(', 'L', pL, pH, 48, 112, 48, 1, 1, c, xL, xH, yL, yH)
+			result = append(result, data...)
+			result = append(result, GS, '(', 'L', 2, 0, 48, 50)
+		}
+	}
+	return result
+}
+
+// ToEscPosBitImageCommand 使用 ESC * 33 按24点双密度列格式输出图像，适用于不支持GS v 0的旧打印机和针式打印机。
+// 每24行为一条，每列3个字节，打印后按24点行距换行，最后恢复默认行距
+func (img *RasterImage) ToEscPosBitImageCommand() []byte {
+	if img == nil || img.Width <= 0 || img.Height <= 0 || img.Content == nil {
+		return nil
+	}
+	const ESC = 0x1B
+	nL, nH := LowHighValue(img.Width)
+	bands := (img.Height + 23) / 24
+	result := make([]byte, 0, 5+bands*(6+img.Width*3)+2)
+	result = append(result, ESC, '3', 24) // 行距24点，条与条之间没有空隙
+	for band := 0; band < bands; band++ {
+		result = append(result, ESC, '*', 33, nL, nH)
+		for x := 0; x < img.Width; x++ {
+			for j := 0; j < 3; j++ {
+				var b byte
+				for i := 0; i < 8; i++ {
+					y := band*24 + j*8 + i
+					if y < img.Height && img.GetPixel(x, y) == 1 {
+						b |= 0x80 >> i
+					}
+				}
+				result = append(result, b)
+			}
+		}
+		result = append(result, '\n')
+	}
+	return append(result, ESC, '2') // 恢复默认行距
+}
+
+// ToEscPosGraphicsCommand 使用 GS ( L <功能112> 将图像存入打印缓冲区后用 <功能50> 打印，
+// 数据超过65535字节时使用 GS 8 L，新型号的Epson打印机使用这种方式打印更快
+func (img *RasterImage) ToEscPosGraphicsCommand() []byte {
+	if img == nil || img.Width <= 0 || img.Height <= 0 || img.Content == nil {
+		return nil
+	}
+	const GS = 0x1D
+	widthBytes := img.Width / 8
+	const maxRows = 1662 // 单条指令的高度限制
+	xL, xH := LowHighValue(img.Width)
+	result := make([]byte, 0, 100+len(img.Content))
+	for offset := 0; offset < img.Height; offset += maxRows {
+		rows := min(maxRows, img.Height-offset)
+		yL, yH := LowHighValue(rows)
+		params := append([]byte{48, 112, 48, 1, 1, 49, xL, xH, yL, yH}, img.Content[offset*widthBytes:(offset+rows)*widthBytes]...)
+		result = append(result, graphicsCommand(params)...)
+		result = append(result, GS, '(', 'L', 2, 0, 48, 50)
+	}
+	return result
+}
+
+// ToEscPosNVGraphicsCommand 使用 GS ( L <功能67> 将图像保存到打印机NV内存中，key为kc1和kc2，
+// 保存后通过ePOS的<logo key1 key2>或 GS ( L <功能69> 打印，不需要每次传送图像数据。
+// NV内存的写入次数有限，只在图像改变时保存。高度超过2304点的部分不保存
+func (img *RasterImage) ToEscPosNVGraphicsCommand(kc1, kc2 byte) []byte {
+	if img == nil || img.Width <= 0 || img.Height <= 0 || img.Content == nil {
+		return nil
+	}
+	const GS = 0x1D
+	rows := min(img.Height, 2304)
+	xL, xH := LowHighValue(img.Width)
+	yL, yH := LowHighValue(rows)
+	params := append([]byte{48, 67, 48, kc1, kc2, 1, xL, xH, yL, yH, 49}, img.Content[:rows*(img.Width/8)]...)
+	result := []byte{GS, '(', 'L', 4, 0, 48, 66, kc1, kc2} // 先删除相同key的图像
+	return append(result, graphicsCommand(params)...)
+}
+
+// graphicsCommand 生成参数为params的图形指令，参数不超过65535字节时使用 GS ( L，否则使用 GS 8 L
+func graphicsCommand(params []byte) []byte {
+	const GS = 0x1D
+	if len(params) <= 0xFFFF {
+		pL, pH := LowHighValue(len(params))
+		return append([]byte{GS, '(', 'L', pL, pH}, params...)
+	}
+	n := len(params)
+	return append([]byte{GS, '8', 'L', byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}, params...)
+}
@@ -0,0 +1,972 @@
+package raster
+
+import (
+	"image"
+	"io"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	escposDLE = 0x10
+	escposHT  = 0x09
+)
+
+// emulatorMaxHeight 模拟打印机最多绘制的高度（点），约8米长的小票。
+// 几个字节的 ESC d 或 ESC J 就可以走纸几百点，超过后的内容不再绘制，避免很短的指令生成巨大的图像
+const emulatorMaxHeight = 65536
+
+// ESC t n 选择的代码页，未列出的代码页按CP437处理
+var emulatorCodePages = map[byte]*charmap.Charmap{
+	0:  charmap.CodePage437,
+	2:  charmap.CodePage850,
+	3:  charmap.CodePage860,
+	4:  charmap.CodePage863,
+	5:  charmap.CodePage865,
+	16: charmap.Windows1252,
+	17: charmap.CodePage866,
+	18: charmap.CodePage852,
+	19: charmap.CodePage858,
+	21: charmap.Windows874,
+	45: charmap.Windows1250,
+	46: charmap.Windows1251,
+	47: charmap.Windows1253,
+	48: charmap.Windows1254,
+	49: charmap.Windows1255,
+	50: charmap.Windows1256,
+	51: charmap.Windows1257,
+	52: charmap.Windows1258,
+}
+
+// 不影响打印结果的指令及其长度（包括前缀和指令字节）
+var (
+	escIgnored = map[byte]int{
+		'K': 3, 'e': 3, 'R': 3, 'p': 5, 'B': 4, 'c': 4, '{': 3, 'V': 3, 'r': 3, 'L': 2, 'S': 2,
+		'T': 3, 'W': 10, '=': 3, 'U': 3, '%': 3, '?': 3, 'C': 3, 'l': 3, 'u': 3, 'v': 2, '<': 2,
+	}
+	gsIgnored = map[byte]int{
+		'b': 3, 'P': 4, '$': 4, '\\': 4, 'T': 3, 'E': 3, 'j': 3, '/': 3, '^': 5, 'c': 2, 'z': 4, 'g': 6,
+	}
+	fsIgnored = map[byte]int{
+		'!': 3, '-': 3, 'S': 4, 'W': 3, 'p': 4,
+	}
+)
+
+// escPosState 可以通过指令修改的打印机设置，ESC @ 恢复为默认值
+type escPosState struct {
+	font          byte // 0: 字体A 12x24，1: 字体B 9x17
+	emphasized    bool
+	underline     int // 下划线粗细（点）
+	reverse       bool
+	widthMul      int // 倍宽
+	heightMul     int // 倍高
+	align         byte
+	lineSpacing   int // 行间距（点）
+	charSpacing   int // 字符右侧间距（点）
+	leftMargin    int
+	areaWidth     int // 打印区域宽度
+	codePage      *charmap.Charmap
+	kanji         bool              // 汉字模式，0x80以上的字节按多字节字符解析
+	kanjiEncoding encoding.Encoding // 汉字模式使用的编码
+	barcodeHeight int
+	barcodeWidth  int  // 条码模块宽度
+	hri           byte // 条码文字位置：0不打印，1上方，2下方，3上下
+	hriFont       byte
+	symbolSize    int // 二维码模块大小
+}
+
+// lineItem 行缓冲区中的一个字符或位图，draw在(x, y)处绘制，y为顶部
+type lineItem struct {
+	x, width, height int
+	draw             func(x, y int)
+}
+
+// EscPosEmulator 解释ESC/POS指令并将打印结果绘制为RasterImage，用于查看原始指令任务的打印效果，
+// 也可以作为模拟打印机使用。指令可以分多次写入，不完整的指令等待后续数据。
+// 支持初始化、代码页和汉字模式文本、对齐、加粗、下划线、反白、倍宽倍高、走纸、切纸（绘制为切割线）、
+// GS v 0和GS ( L光栅图像、ESC *位图以及EAN/UPC、CODE39、ITF、CODE128条码。
+// 字体只包含常用的ASCII字符，其他字符和不支持的条码、二维码绘制为占位图形；页模式按标准模式顺序绘制
+type EscPosEmulator struct {
+	Response io.Writer               // 实时状态查询（DLE EOT、GS a等）的响应，为nil时不响应
+	Status   func(cmd []byte) []byte // 返回DLE EOT n、GS a n和GS r n的响应，为nil时返回正常状态
+
+	width      int
+	canvas     *RasterImage
+	y          int // 下一行的顶部位置
+	pageStart  int // 上一次切纸的位置
+	pending    []byte
+	line       []lineItem
+	lineX      int
+	graphics   *RasterImage // GS ( L 图形缓冲区
+	graphicsSx int
+	graphicsSy int
+	symbol     []byte // GS ( k 保存的二维码数据
+	symbolType byte
+	escPosState
+}
+
+// NewEscPosEmulator 创建纸张宽度为width点的模拟打印机，width<=0时使用576
+func NewEscPosEmulator(width int) *EscPosEmulator {
+	if width <= 0 {
+		width = 576
+	}
+	e := &EscPosEmulator{canvas: NewRasterImage(width, 0)}
+	e.width = e.canvas.Width
+	e.reset()
+	return e
+}
+
+// NewRasterImageFromEscPos 将ESC/POS指令绘制为图像，没有打印内容时返回nil
+func NewRasterImageFromEscPos(data []byte, width int) *RasterImage {
+	e := NewEscPosEmulator(width)
+	e.Write(data)
+	return e.Image()
+}
+
+func (e *EscPosEmulator) reset() {
+	e.escPosState = escPosState{
+		widthMul:      1,
+		heightMul:     1,
+		lineSpacing:   30,
+		areaWidth:     e.width,
+		codePage:      charmap.CodePage437,
+		kanjiEncoding: simplifiedchinese.GB18030,
+		barcodeHeight: 162,
+		barcodeWidth:  3,
+		symbolSize:    3,
+	}
+	e.line = nil
+	e.lineX = 0
+}
+
+// Write 解释指令，总是接收全部数据
+func (e *EscPosEmulator) Write(p []byte) (int, error) {
+	e.pending = append(e.pending, p...)
+	pos := 0
+	for pos < len(e.pending) {
+		n := e.step(e.pending[pos:])
+		if n == 0 {
+			break // 指令不完整
+		}
+		pos += n
+	}
+	e.pending = append(e.pending[:0], e.pending[pos:]...)
+	return len(p), nil
+}
+
+// Image 打印行缓冲区中剩余的内容并返回到目前为止的打印结果，没有打印内容时返回nil
+func (e *EscPosEmulator) Image() *RasterImage {
+	e.flushLine()
+	height := max(e.y, e.canvas.Height)
+	if height == 0 || e.canvas.Height == 0 {
+		return nil
+	}
+	img := NewRasterImage(e.width, height)
+	copy(img.Content, e.canvas.Content)
+	return img
+}
+
+func (e *EscPosEmulator) respond(b ...byte) {
+	if e.Response != nil && len(b) > 0 {
+		e.Response.Write(b)
+	}
+}
+
+// query 响应状态查询指令
+func (e *EscPosEmulator) query(cmd []byte) {
+	if e.Status != nil {
+		e.respond(e.Status(cmd)...)
+		return
+	}
+	switch {
+	case cmd[0] == escposDLE && cmd[2] >= 1 && cmd[2] <= 4:
+		e.respond(0x12) // 在线，没有错误
+	case cmd[1] == 'a' && cmd[2] != 0: // 开启自动状态返回时立即返回一次状态
+		e.respond(0x10, 0x00, 0x00, 0x00)
+	case cmd[1] == 'r':
+		e.respond(0x00)
+	}
+}
+
+// step 解释一条指令并返回指令长度，指令不完整时返回0
+func (e *EscPosEmulator) step(b []byte) int {
+	switch b[0] {
+	case escposLF:
+		e.printLine(e.lineSpacing)
+		return 1
+	case escposFF:
+		e.flushLine()
+		return 1
+	case escposHT:
+		tab := 8 * (e.charWidth() + e.charSpacing) * e.widthMul
+		e.lineX = (e.lineX/tab + 1) * tab
+		return 1
+	case escposDLE:
+		return e.realTime(b)
+	case escposESC:
+		if len(b) < 2 {
+			return 0
+		}
+		return e.esc(b)
+	case escposGS:
+		if len(b) < 2 {
+			return 0
+		}
+		return e.gs(b)
+	case escposFS:
+		if len(b) < 2 {
+			return 0
+		}
+		return e.fs(b)
+	}
+	if b[0] < 0x20 {
+		return 1 // CR等其他控制字符
+	}
+	return e.text(b)
+}
+
+// realTime 解释 DLE 开头的实时指令
+func (e *EscPosEmulator) realTime(b []byte) int {
+	if len(b) < 3 {
+		return 0
+	}
+	switch b[1] {
+	case 0x04: // DLE EOT n
+		e.query(b[:3])
+		return 3
+	case 0x05: // DLE ENQ n
+		return 3
+	case 0x14: // DLE DC4 fn ...
+		if b[2] == 8 {
+			return need(b, 10)
+		}
+		return need(b, 5)
+	}
+	return 1
+}
+
+// esc 解释 ESC 开头的指令
+func (e *EscPosEmulator) esc(b []byte) int {
+	if n, ok := escIgnored[b[1]]; ok {
+		return need(b, n)
+	}
+	switch b[1] {
+	case '@':
+		e.reset()
+		return 2
+	case 'i', 'm':
+		e.cut()
+		return 2
+	case '2':
+		e.lineSpacing = 30
+		return 2
+	case '*':
+		return e.bitImage(b)
+	case '&':
+		return e.userDefinedChars(b)
+	case 'D':
+		for i := 2; i < len(b); i++ {
+			if b[i] == 0 || i == 34 {
+				return i + 1
+			}
+		}
+		return 0
+	case '(':
+		return e.extended(b, 0)
+	case '$', '\\':
+		if len(b) < 4 {
+			return 0
+		}
+		pos := int(b[2]) | int(b[3])<<8
+		if b[1] == '$' {
+			e.lineX = pos
+		} else {
+			e.lineX = max(e.lineX+int(int16(pos)), 0)
+		}
+		return 4
+	}
+	if len(b) < 3 {
+		return 0
+	}
+	n := b[2]
+	switch b[1] {
+	case '!':
+		e.font = n & 0x01
+		e.emphasized = n&0x08 != 0
+		e.heightMul = 1 + int(n>>4&1)
+		e.widthMul = 1 + int(n>>5&1)
+		e.underline = int(n >> 7 & 1)
+	case 'E', 'G':
+		e.emphasized = n&1 != 0
+	case '-':
+		e.underline = int(n % 48 & 0x03)
+	case 'M':
+		e.font = n & 0x01
+	case 'a':
+		e.align = n % 48
+	case 'd':
+		e.printLine(int(n) * e.lineSpacing)
+	case 'J':
+		e.printLine(int(n))
+	case '3':
+		e.lineSpacing = int(n)
+	case ' ':
+		e.charSpacing = int(n)
+	case 't':
+		e.codePage = emulatorCodePages[n]
+		if e.codePage == nil {
+			e.codePage = charmap.CodePage437
+		}
+	}
+	return 3
+}
+
+// gs 解释 GS 开头的指令
+func (e *EscPosEmulator) gs(b []byte) int {
+	if n, ok := gsIgnored[b[1]]; ok {
+		return need(b, n)
+	}
+	switch b[1] {
+	case 'v':
+		return e.rasterImage(b)
+	case 'k':
+		return e.barcodeCommand(b)
+	case '(':
+		return e.extended(b, 0)
+	case '8':
+		if len(b) < 3 {
+			return 0
+		}
+		if b[2] != 'L' {
+			return 3
+		}
+		return e.extended(b, 1)
+	case '*':
+		if len(b) < 4 {
+			return 0
+		}
+		return need(b, 4+int(b[2])*int(b[3])*8)
+	case 'V':
+		if len(b) < 3 {
+			return 0
+		}
+		m := b[2]
+		switch m {
+		case 65, 66, 97, 98:
+			if len(b) < 4 {
+				return 0
+			}
+			e.printLine(int(b[3]))
+			e.cut()
+			return 4
+		case 103, 104:
+			if len(b) < 4 {
+				return 0
+			}
+			e.cut()
+			return 4
+		}
+		e.cut()
+		return 3
+	case 'L', 'W':
+		if len(b) < 4 {
+			return 0
+		}
+		n := int(b[2]) | int(b[3])<<8
+		if b[1] == 'L' {
+			e.leftMargin = min(n, e.width-8)
+		} else {
+			e.areaWidth = n
+		}
+		e.areaWidth = clamp(e.areaWidth, 8, e.width-e.leftMargin)
+		return 4
+	}
+	if len(b) < 3 {
+		return 0
+	}
+	n := b[2]
+	switch b[1] {
+	case '!':
+		e.widthMul = 1 + int(n>>4&0x07)
+		e.heightMul = 1 + int(n&0x07)
+	case 'B':
+		e.reverse = n&1 != 0
+	case 'h':
+		e.barcodeHeight = max(int(n), 1)
+	case 'w':
+		e.barcodeWidth = clamp(int(n), 1, 6)
+	case 'H':
+		e.hri = n % 48
+	case 'f':
+		e.hriFont = n % 48 & 0x01
+	case 'a', 'r':
+		e.query(b[:3])
+	case 'I':
+		e.printerInfo(n)
+	}
+	return 3
+}
+
+// printerInfo 响应 GS I n 查询打印机信息
+func (e *EscPosEmulator) printerInfo(n byte) {
+	info := map[byte]string{65: "1.0", 66: "ODOO-EPOS", 67: "ESC/POS Emulator", 68: "0", 69: "ESC/POS"}
+	switch {
+	case n == 1 || n == 49:
+		e.respond(0x20)
+	case n == 2 || n == 3 || n == 50 || n == 51:
+		e.respond(0x00)
+	case info[n] != "":
+		e.respond(append(append([]byte{0x5F}, info[n]...), 0x00)...)
+	}
+}
+
+// fs 解释 FS 开头的指令
+func (e *EscPosEmulator) fs(b []byte) int {
+	if n, ok := fsIgnored[b[1]]; ok {
+		return need(b, n)
+	}
+	switch b[1] {
+	case '&':
+		e.kanji = true
+		return 2
+	case '.':
+		e.kanji = false
+		return 2
+	case '(':
+		return e.extended(b, 0)
+	case '2': // 自定义汉字 FS 2 c1 c2 d1...d72
+		return need(b, 4+72)
+	case 'C':
+		if len(b) < 3 {
+			return 0
+		}
+		switch b[2] {
+		case 1, 2, 49, 50:
+			e.kanjiEncoding = japanese.ShiftJIS
+		default:
+			e.kanjiEncoding = simplifiedchinese.GB18030
+		}
+		return 3
+	case 'q': // 定义NV位图 FS q n [xL xH yL yH d...]...
+		if len(b) < 3 {
+			return 0
+		}
+		pos := 3
+		for range int(b[2]) {
+			if len(b) < pos+4 {
+				return 0
+			}
+			x := int(b[pos]) | int(b[pos+1])<<8
+			y := int(b[pos+2]) | int(b[pos+3])<<8
+			pos += 4 + x*y*8
+		}
+		return need(b, pos)
+	}
+	return 2
+}
+
+// need 数据足够时返回指令长度n，否则返回0
+func need(b []byte, n int) int {
+	if len(b) < n {
+		return 0
+	}
+	return n
+}
+
+// extended 解释带参数长度的指令：ESC ( x、GS ( x和FS ( x的参数长度为2字节，GS 8 L为4字节（wide为1）
+func (e *EscPosEmulator) extended(b []byte, wide int) int {
+	header := 5
+	if wide == 1 {
+		header = 7
+	}
+	if len(b) < header {
+		return 0
+	}
+	var p int
+	if wide == 1 {
+		p = int(b[3]) | int(b[4])<<8 | int(b[5])<<16 | int(b[6])<<24
+	} else {
+		p = int(b[3]) | int(b[4])<<8
+	}
+	total := header + p
+	if len(b) < total {
+		return 0
+	}
+	params := b[header:total]
+	switch {
+	case b[0] == escposGS && (b[2] == 'L' || b[1] == '8'):
+		e.graphicsCommand(params)
+	case b[0] == escposGS && b[2] == 'k':
+		e.symbolCommand(params)
+	}
+	return total
+}
+
+// text 将一个字符加入行缓冲区
+func (e *EscPosEmulator) text(b []byte) int {
+	if !e.kanji || b[0] < 0x80 {
+		r := rune(b[0])
+		if b[0] >= 0x80 {
+			r = e.codePage.DecodeByte(b[0])
+		}
+		e.addChar(r, false)
+		return 1
+	}
+	n := 2
+	switch e.kanjiEncoding {
+	case japanese.ShiftJIS:
+		if b[0] >= 0xA1 && b[0] <= 0xDF {
+			n = 1 // 半角片假名
+		}
+	default:
+		if len(b) < 2 {
+			return 0
+		}
+		if b[1] >= 0x30 && b[1] <= 0x39 {
+			n = 4 // GB18030 四字节字符
+		}
+	}
+	if len(b) < n {
+		return 0
+	}
+	r := rune(0)
+	if decoded, err := e.kanjiEncoding.NewDecoder().Bytes(b[:n]); err == nil && len(decoded) > 0 {
+		r = []rune(string(decoded))[0]
+	}
+	e.addChar(r, n > 1)
+	return n
+}
+
+func (e *EscPosEmulator) charWidth() int {
+	if e.font == 1 {
+		return 9
+	}
+	return 12
+}
+
+func (e *EscPosEmulator) charHeight() int {
+	if e.font == 1 {
+		return 17
+	}
+	return 24
+}
+
+// addChar 按当前字体和样式将字符加入行缓冲区，wide为汉字等全角字符
+func (e *EscPosEmulator) addChar(r rune, wide bool) {
+	w, h := e.charWidth(), e.charHeight()
+	if wide {
+		w = h
+	}
+	st := e.escPosState
+	w, h = w*st.widthMul, h*st.heightMul
+	spacing := st.charSpacing * st.widthMul
+	e.addItem(w+spacing, h, func(x, y int) {
+		e.drawGlyph(r, x, y, w, h, st.emphasized)
+		if st.underline > 0 {
+			e.fill(x, y+h-st.underline, w+spacing, st.underline)
+		}
+		if st.reverse {
+			e.invert(x, y, w+spacing, h)
+		}
+	})
+}
+
+// drawGlyph 将16x24点阵字体缩放到w x h绘制，字体中没有的字符先去掉变音符号，仍然没有时绘制占位字符
+func (e *EscPosEmulator) drawGlyph(r rune, x, y, w, h int, emphasized bool) {
+	if r == ' ' || r == 0x3000 {
+		return
+	}
+	glyph, ok := Fonts16x24[r]
+	if !ok {
+		if base := []rune(norm.NFD.String(string(r))); len(base) > 0 {
+			glyph, ok = Fonts16x24[base[0]]
+		}
+	}
+	if !ok {
+		glyph = Fonts16x24[0]
+	}
+	for dy := range h {
+		row := glyph[dy*24/h]
+		for dx := range w {
+			sx := dx * 16 / w
+			if row[sx/8]&(0x80>>(sx%8)) != 0 {
+				e.dot(x+dx, y+dy)
+				if emphasized {
+					e.dot(x+dx+1, y+dy)
+				}
+			}
+		}
+	}
+}
+
+// addItem 将字符或位图加入行缓冲区，超出打印区域时先打印当前行
+func (e *EscPosEmulator) addItem(width, height int, draw func(x, y int)) {
+	if len(e.line) > 0 && e.lineX+width > e.areaWidth {
+		e.printLine(e.lineSpacing)
+	}
+	e.line = append(e.line, lineItem{x: e.lineX, width: width, height: height, draw: draw})
+	e.lineX += width
+}
+
+// printLine 按对齐方式打印行缓冲区并走纸feed点，行高超过feed时按行高走纸
+func (e *EscPosEmulator) printLine(feed int) {
+	lineHeight := 0
+	for _, item := range e.line {
+		lineHeight = max(lineHeight, item.height)
+	}
+	x := e.alignX(e.lineX)
+	for _, item := range e.line {
+		item.draw(x+item.x, e.y+lineHeight-item.height) // 底部对齐
+	}
+	e.advance(max(feed, lineHeight))
+	e.line = nil
+	e.lineX = 0
+}
+
+// flushLine 打印行缓冲区中的内容，不额外走纸
+func (e *EscPosEmulator) flushLine() {
+	if len(e.line) > 0 {
+		e.printLine(0)
+	}
+}
+
+func (e *EscPosEmulator) alignX(width int) int {
+	switch e.align {
+	case 1:
+		return e.leftMargin + max(e.areaWidth-width, 0)/2
+	case 2:
+		return e.leftMargin + max(e.areaWidth-width, 0)
+	}
+	return e.leftMargin
+}
+
+// cut 在当前位置绘制切割线，上一次切纸后没有打印内容时忽略
+func (e *EscPosEmulator) cut() {
+	e.flushLine()
+	if e.y == e.pageStart || e.y >= emulatorMaxHeight {
+		return
+	}
+	e.grow(e.y + 1)
+	copy(e.canvas.GetRow(e.y), CUTLINE)
+	e.advance(1)
+	e.pageStart = e.y
+}
+
+// printBlock 在新的一行按对齐方式绘制宽width高height的图形，如光栅图像和条码
+func (e *EscPosEmulator) printBlock(width, height int, draw func(x, y int)) {
+	e.flushLine()
+	draw(e.alignX(width), e.y)
+	e.advance(height)
+}
+
+// advance 纸张向前移动n点，最多到emulatorMaxHeight
+func (e *EscPosEmulator) advance(n int) {
+	e.y = min(e.y+n, emulatorMaxHeight)
+}
+
+// printImage 按sx、sy倍放大打印光栅图像
+func (e *EscPosEmulator) printImage(img *RasterImage, sx, sy int) {
+	e.printBlock(img.Width*sx, img.Height*sy, func(x, y int) {
+		for iy := range img.Height {
+			for ix := range img.Width {
+				if img.GetPixel(ix, iy) == 1 {
+					e.fill(x+ix*sx, y+iy*sy, sx, sy)
+				}
+			}
+		}
+	})
+}
+
+// rasterImage 解释 GS v 0 m xL xH yL yH d...
+func (e *EscPosEmulator) rasterImage(b []byte) int {
+	if len(b) < 3 {
+		return 0
+	}
+	if b[2] != '0' {
+		return 3
+	}
+	if len(b) < 8 {
+		return 0
+	}
+	m := b[3]
+	xBytes := int(b[4]) | int(b[5])<<8
+	height := int(b[6]) | int(b[7])<<8
+	total := 8 + xBytes*height
+	if len(b) < total {
+		return 0
+	}
+	if xBytes > 0 && height > 0 {
+		img := NewRasterImage(xBytes*8, height)
+		copy(img.Content, b[8:total])
+		e.printImage(img, 1+int(m&1), 1+int(m>>1&1))
+	}
+	return total
+}
+
+// bitImage 解释 ESC * m nL nH d...，按列排列的位图作为字符加入行缓冲区
+func (e *EscPosEmulator) bitImage(b []byte) int {
+	if len(b) < 5 {
+		return 0
+	}
+	m := b[2]
+	columns := int(b[3]) | int(b[4])<<8
+	k, sx, sy := 1, 1, 3 // 8点垂直双密度/单密度
+	switch m {
+	case 0:
+		sx = 2
+	case 32:
+		k, sx, sy = 3, 2, 1
+	case 33:
+		k, sy = 3, 1
+	}
+	total := 5 + columns*k
+	if len(b) < total {
+		return 0
+	}
+	data := append([]byte(nil), b[5:total]...)
+	e.addItem(columns*sx, k*8*sy, func(x, y int) {
+		for c := range columns {
+			for j := range k {
+				d := data[c*k+j]
+				for i := range 8 {
+					if d&(0x80>>i) != 0 {
+						e.fill(x+c*sx, y+(j*8+i)*sy, sx, sy)
+					}
+				}
+			}
+		}
+	})
+	return total
+}
+
+// userDefinedChars 跳过 ESC & y c1 c2 [x d1...d(y*x)]...
+func (e *EscPosEmulator) userDefinedChars(b []byte) int {
+	if len(b) < 5 {
+		return 0
+	}
+	y := int(b[2])
+	pos := 5
+	for range max(int(b[4])-int(b[3])+1, 0) {
+		if len(b) <= pos {
+			return 0
+		}
+		pos += 1 + y*int(b[pos])
+	}
+	return need(b, pos)
+}
+
+// graphicsCommand 解释 GS ( L 和 GS 8 L 的光栅图形功能：<功能112>存入缓冲区，<功能50>打印缓冲区
+func (e *EscPosEmulator) graphicsCommand(params []byte) {
+	if len(params) < 2 {
+		return
+	}
+	switch params[1] {
+	case 112:
+		if len(params) < 10 {
+			return
+		}
+		width := int(params[6]) | int(params[7])<<8
+		height := int(params[8]) | int(params[9])<<8
+		rowBytes := (width + 7) / 8
+		data := params[10:]
+		if width == 0 || height == 0 || len(data) < rowBytes*height {
+			return
+		}
+		img := NewRasterImage(width, height)
+		copy(img.Content, data)
+		// 双色打印时每种颜色分别存入缓冲区，模拟打印机将所有颜色合并为黑色
+		if g := e.graphics; g != nil && g.Width == img.Width && g.Height == img.Height {
+			for i := range g.Content {
+				g.Content[i] |= img.Content[i]
+			}
+		} else {
+			e.graphics = img
+		}
+		e.graphicsSx = clamp(int(params[3]), 1, 2)
+		e.graphicsSy = clamp(int(params[4]), 1, 2)
+	case 2, 50:
+		if e.graphics != nil {
+			e.printImage(e.graphics, e.graphicsSx, e.graphicsSy)
+			e.graphics = nil
+		}
+	}
+}
+
+// symbolCommand 解释 GS ( k 二维码指令，打印时绘制占位图形
+func (e *EscPosEmulator) symbolCommand(params []byte) {
+	if len(params) < 2 {
+		return
+	}
+	cn, fn := params[0], params[1]
+	switch {
+	case fn == 67 && len(params) > 2 && (cn == 49 || cn == 53 || cn == 54):
+		e.symbolSize = clamp(int(params[2]), 1, 16)
+	case fn == 80 && len(params) > 2:
+		e.symbol = append([]byte(nil), params[3:]...)
+		e.symbolType = cn
+	case fn == 81 && e.symbol != nil && e.symbolType == cn:
+		e.printSymbol()
+	}
+}
+
+// printSymbol 按数据长度估算二维码大小并绘制带定位图案的占位图形
+func (e *EscPosEmulator) printSymbol() {
+	modules := 21 + 4*clamp(len(e.symbol)/16, 0, 39)
+	size := e.symbolSize
+	e.printBlock(modules*size, modules*size, func(x, y int) {
+		finder := func(fx, fy int) {
+			e.fill(x+fx*size, y+fy*size, 7*size, 7*size)
+			e.invert(x+(fx+1)*size, y+(fy+1)*size, 5*size, 5*size)
+			e.invert(x+(fx+2)*size, y+(fy+2)*size, 3*size, 3*size)
+		}
+		finder(0, 0)
+		finder(modules-7, 0)
+		finder(0, modules-7)
+		e.outline(x, y, modules*size, modules*size, size)
+	})
+}
+
+// barcodeCommand 解释 GS k m d1...dk NUL 和 GS k m n d1...dn
+func (e *EscPosEmulator) barcodeCommand(b []byte) int {
+	if len(b) < 4 {
+		return 0
+	}
+	m := b[2]
+	if m <= 6 {
+		for i := 3; i < len(b); i++ {
+			if b[i] == 0 {
+				e.barcode(m+65, b[3:i])
+				return i + 1
+			}
+		}
+		return 0
+	}
+	total := 4 + int(b[3])
+	if len(b) < total {
+		return 0
+	}
+	e.barcode(m, b[4:total])
+	return total
+}
+
+// barcode 绘制条码和条码文字，不支持的条码类型绘制为带文字的方框
+func (e *EscPosEmulator) barcode(m byte, data []byte) {
+	var modules []bool
+	hri := string(data)
+	switch m {
+	case 65: // UPC-A 即首位为0的EAN-13
+		modules, hri = eanModules(append([]byte{'0'}, data...), 13)
+		if len(hri) > 0 {
+			hri = hri[1:]
+		}
+	case 67:
+		modules, hri = eanModules(data, 13)
+	case 68:
+		modules, hri = eanModules(data, 8)
+	case 69:
+		modules, hri = code39Modules(data)
+	case 70:
+		modules = itfModules(data)
+	case 73:
+		modules, hri = code128Modules(data)
+	}
+
+	n, h := e.barcodeWidth, e.barcodeHeight
+	width := len(modules) * n
+	if modules == nil {
+		width = (len(data)*11 + 35) * n
+	}
+	font := e.font
+	e.font = e.hriFont
+	textWidth, textHeight := len([]rune(hri))*e.charWidth(), e.charHeight()
+	e.font = font
+	above, below := e.hri&1 != 0, e.hri&2 != 0
+	height := h
+	if above {
+		height += textHeight + 2
+	}
+	if below {
+		height += textHeight + 2
+	}
+
+	e.printBlock(width, height, func(x, y int) {
+		top := y
+		if above {
+			e.drawText(hri, x+(width-textWidth)/2, y, e.hriFont)
+			top += textHeight + 2
+		}
+		if modules == nil {
+			e.outline(x, top, width, h, 2)
+			e.drawText(string(data), x+(width-len(data)*12)/2, top+(h-24)/2, 0)
+		}
+		for i, bar := range modules {
+			if bar {
+				e.fill(x+i*n, top, n, h)
+			}
+		}
+		if below {
+			e.drawText(hri, x+(width-textWidth)/2, top+h+2, e.hriFont)
+		}
+	})
+}
+
+// drawText 使用字体A或B绘制一行文字（用于条码文字）
+func (e *EscPosEmulator) drawText(s string, x, y int, font byte) {
+	w, h := 12, 24
+	if font == 1 {
+		w, h = 9, 17
+	}
+	for i, r := range []rune(s) {
+		e.drawGlyph(r, x+i*w, y, w, h, false)
+	}
+}
+
+func (e *EscPosEmulator) grow(height int) {
+	if height > e.canvas.Height {
+		e.canvas.Content = append(e.canvas.Content, make([]byte, (height-e.canvas.Height)*e.width/8)...)
+		e.canvas.Height = height
+	}
+}
+
+// dot 将一个点设为黑色，超出纸张宽度或最大高度的点被忽略
+func (e *EscPosEmulator) dot(x, y int) {
+	if x < 0 || x >= e.width || y < 0 || y >= emulatorMaxHeight {
+		return
+	}
+	e.grow(y + 1)
+	e.canvas.SetPixelBlack(x, y)
+}
+
+func (e *EscPosEmulator) fill(x, y, w, h int) {
+	for dy := range h {
+		for dx := range w {
+			e.dot(x+dx, y+dy)
+		}
+	}
+}
+
+// invert 反转区域内的颜色
+func (e *EscPosEmulator) invert(x, y, w, h int) {
+	for dy := range h {
+		for dx := range w {
+			px, py := x+dx, y+dy
+			if px < 0 || px >= e.width || py < 0 || py >= emulatorMaxHeight {
+				continue
+			}
+			e.grow(py + 1)
+			e.canvas.SetPixel(image.Point{px, py}, 1-e.canvas.GetPixel(px, py))
+		}
+	}
+}
+
+// outline 绘制线宽为t的方框
+func (e *EscPosEmulator) outline(x, y, w, h, t int) {
+	e.fill(x, y, w, t)
+	e.fill(x, y+h-t, w, t)
+	e.fill(x, y, t, h)
+	e.fill(x+w-t, y, t, h)
+}
@@ -0,0 +1,155 @@
+package raster
+
+import (
+	"bytes"
+	"testing"
+)
+
+// inkBounds 返回图像中黑点的个数和最左、最右、最下一个黑点的位置
+func inkBounds(img *RasterImage) (count, left, right, bottom int) {
+	left = -1
+	for y := range img.Height {
+		for x := range img.Width {
+			if img.GetPixel(x, y) == 1 {
+				if left < 0 || x < left {
+					left = x
+				}
+				count++
+				right = max(right, x)
+				bottom = y
+			}
+		}
+	}
+	return count, left, right, bottom
+}
+
+func TestEscPosEmulatorText(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		left, right int // 黑点所在的范围
+	}{
+		{"left", "\x1b@I\n", 0, 11},
+		{"center", "\x1b@\x1ba\x01I\n", 282, 293},
+		{"right", "\x1b@\x1ba\x02I\n", 564, 575},
+		{"double width", "\x1b@\x1d!\x10I\n", 0, 23},
+		{"font b", "\x1b@\x1bM\x01\x1ba\x02I\n", 567, 575},
+		{"left margin", "\x1b@\x1dL\x40\x00I\n", 64, 75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := NewRasterImageFromEscPos([]byte(tt.data), 576)
+			if img == nil {
+				t.Fatal("nothing printed")
+			}
+			count, left, right, _ := inkBounds(img)
+			if count == 0 || left < tt.left || right > tt.right {
+				t.Errorf("ink from x=%d to %d, want within %d..%d", left, right, tt.left, tt.right)
+			}
+		})
+	}
+}
+
+func TestEscPosEmulatorImages(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		count, height int // 黑点的个数和图像高度
+	}{
+		{"GS v 0", []byte{0x1d, 'v', '0', 0, 2, 0, 2, 0, 0xff, 0xff, 0xff, 0xff}, 32, 2},
+		{"GS v 0 double", []byte{0x1d, 'v', '0', 3, 1, 0, 1, 0, 0xff}, 32, 2},
+		{"ESC * 24 dot", []byte{0x1b, '*', 33, 1, 0, 0xff, 0xff, 0xff, '\n'}, 24, 30},
+		{"ESC * 8 dot", []byte{0x1b, '*', 1, 1, 0, 0x80, '\n'}, 3, 30},
+		{"GS ( L", []byte{0x1d, '(', 'L', 11, 0, 48, 112, 48, 1, 1, 49, 8, 0, 1, 0, 0xff, 0x1d, '(', 'L', 2, 0, 48, 50}, 8, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := NewRasterImageFromEscPos(tt.data, 576)
+			if img == nil {
+				t.Fatal("nothing printed")
+			}
+			if count, _, _, _ := inkBounds(img); count != tt.count || img.Height != tt.height {
+				t.Errorf("%d black dots, height %d, want %d dots, height %d", count, img.Height, tt.count, tt.height)
+			}
+		})
+	}
+}
+
+func TestEscPosEmulatorBarcodes(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"EAN13", append([]byte{0x1d, 'k', 67, 12}, "490123456789"...)},
+		{"UPC-A", append([]byte{0x1d, 'k', 0}, "01234567890\x00"...)},
+		{"CODE39", append([]byte{0x1d, 'k', 4}, "ABC-123\x00"...)},
+		{"ITF", append([]byte{0x1d, 'k', 70, 4}, "1234"...)},
+		{"CODE128", append([]byte{0x1d, 'k', 73, 6}, "{BTest"...)},
+		{"unsupported", append([]byte{0x1d, 'k', 72, 4}, "1234"...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 条码高度80点，下方打印文字
+			data := append([]byte{0x1d, 'h', 80, 0x1d, 'H', 2}, tt.data...)
+			img := NewRasterImageFromEscPos(data, 576)
+			if img == nil {
+				t.Fatal("nothing printed")
+			}
+			count, left, right, bottom := inkBounds(img)
+			if count == 0 || right-left < 40 || bottom < 80 {
+				t.Errorf("barcode from x=%d to %d, bottom %d, want a bar code at least 80 dots high with text below", left, right, bottom)
+			}
+		})
+	}
+}
+
+func TestEscPosEmulatorMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"lone ESC", []byte{0x1b}},
+		{"truncated GS v 0", []byte{0x1d, 'v', '0', 0, 2, 0, 2, 0, 0xff}},
+		{"truncated ESC *", []byte{0x1b, '*', 33, 0xff, 0xff, 0}},
+		{"truncated GS 8 L", []byte{0x1d, '8', 'L', 0xff, 0xff, 0xff, 0x7f, 48}},
+		{"GS ( L short data", []byte{0x1d, '(', 'L', 11, 0, 48, 112, 48, 1, 1, 49, 0xff, 0xff, 0xff, 0xff, 0}},
+		{"unterminated barcode", []byte{0x1d, 'k', 4, 'A', 'B'}},
+		{"bad code page", []byte{0x1b, 't', 200, 0xe9, '\n'}},
+		{"unknown commands", []byte{0x1b, 0xff, 0x1d, 0xff, 0x1c, 0xff, 0x10, 0xff, 0}},
+		{"kanji without second byte", []byte{0x1c, '&', 0xb0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEscPosEmulator(576)
+			if n, err := e.Write(tt.data); n != len(tt.data) || err != nil {
+				t.Errorf("Write = %d, %v, want %d, nil", n, err, len(tt.data))
+			}
+			e.Image()
+		})
+	}
+}
+
+func TestEscPosEmulatorSplitWrites(t *testing.T) {
+	data := []byte("\x1b@\x1ba\x01Hello\n\x1dv0\x00\x02\x00\x02\x00\xff\xff\xff\xff\x1dV\x00")
+	want := NewRasterImageFromEscPos(data, 576)
+	e := NewEscPosEmulator(576)
+	for _, b := range data {
+		e.Write([]byte{b}) // 指令不完整时等待后续数据
+	}
+	if got := e.Image(); got == nil || !bytes.Equal(got.Content, want.Content) {
+		t.Error("writing byte by byte printed a different image")
+	}
+}
+
+func TestEscPosEmulatorFeedLimit(t *testing.T) {
+	// 每6个字节走纸65025点
+	data := append([]byte("I\n"), bytes.Repeat([]byte{0x1b, '3', 255, 0x1b, 'd', 255}, 1000)...)
+	img := NewRasterImageFromEscPos(data, 576)
+	if img == nil || img.Height > emulatorMaxHeight {
+		t.Fatalf("printed %v, want at most %d dots high", img, emulatorMaxHeight)
+	}
+	data = append([]byte("I\n"), bytes.Repeat([]byte{0x1b, 'J', 255}, 1000)...)
+	if img := NewRasterImageFromEscPos(data, 576); img == nil || img.Height > emulatorMaxHeight {
+		t.Fatalf("printed %v, want at most %d dots high", img, emulatorMaxHeight)
+	}
+}
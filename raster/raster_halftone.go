@@ -0,0 +1,124 @@
+package raster
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+// 半色调处理方式，与ePOS-Print <image halftone> 属性一致
+const (
+	HalftoneDither         = "dither"          // 有序抖动（Bayer矩阵）
+	HalftoneErrorDiffusion = "error_diffusion" // 误差扩散（Floyd-Steinberg）
+	HalftoneThreshold      = "threshold"       // 固定阈值
+)
+
+// bayer8x8 有序抖动矩阵，取值0~63
+var bayer8x8 = [8][8]int{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// NewRasterImageFromGray 将灰度图像转换为二值RasterImage
+// halftone: 半色调处理方式，为空时使用误差扩散
+// brightness: 伽马校正值（0.1~10.0），1.0表示不调整，数值越大图像越亮
+func NewRasterImageFromGray(gray *image.Gray, halftone string, brightness float64) *RasterImage {
+	if gray == nil {
+		return nil
+	}
+	bounds := gray.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	rs := NewRasterImage(w, h)
+
+	// 亮度校正，使用查找表避免逐像素计算幂函数
+	var lut [256]int
+	if brightness <= 0 {
+		brightness = 1.0
+	}
+	brightness = math.Max(0.1, math.Min(brightness, 10.0))
+	for i := range lut {
+		lut[i] = int(math.Round(255 * math.Pow(float64(i)/255, 1/brightness)))
+	}
+	levels := make([]int, w*h)
+	for y := range h {
+		for x := range w {
+			levels[y*w+x] = lut[gray.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y]
+		}
+	}
+
+	switch strings.ToLower(halftone) {
+	case HalftoneThreshold:
+		for y := range h {
+			for x := range w {
+				if levels[y*w+x] < 128 {
+					rs.SetPixelBlack(x, y)
+				}
+			}
+		}
+	case HalftoneDither:
+		for y := range h {
+			for x := range w {
+				threshold := (bayer8x8[y%8][x%8]*4 + 2) // 映射到0~255
+				if levels[y*w+x] < threshold {
+					rs.SetPixelBlack(x, y)
+				}
+			}
+		}
+	default: // HalftoneErrorDiffusion
+		for y := range h {
+			for x := range w {
+				old := levels[y*w+x]
+				value := 255
+				if old < 128 {
+					value = 0
+					rs.SetPixelBlack(x, y)
+				}
+				quantError := old - value
+				if x+1 < w {
+					levels[y*w+x+1] += quantError * 7 / 16
+				}
+				if y+1 < h {
+					if x > 0 {
+						levels[(y+1)*w+x-1] += quantError * 3 / 16
+					}
+					levels[(y+1)*w+x] += quantError * 5 / 16
+					if x+1 < w {
+						levels[(y+1)*w+x+1] += quantError * 1 / 16
+					}
+				}
+			}
+		}
+	}
+	return rs
+}
+
+// decodeGray16 解码ePOS-Print gray16格式的图片数据，
+// 每个像素4位，每行按字节对齐，数值表示浓度（0为白色，15为黑色）。
+// 数据不完整时缺少的部分为白色
+func decodeGray16(width, height int, content []byte) *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range gray.Pix {
+		gray.Pix[i] = 255
+	}
+	rowBytes := (width + 1) / 2
+	for y := range height {
+		for x := range width {
+			index := y*rowBytes + x/2
+			if index >= len(content) {
+				return gray
+			}
+			density := content[index] >> 4
+			if x%2 == 1 {
+				density = content[index] & 0x0F
+			}
+			gray.Pix[y*gray.Stride+x] = 255 - density*17
+		}
+	}
+	return gray
+}
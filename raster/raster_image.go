@@ -8,11 +8,13 @@ import (
 
 // EPOSImage 表示图片数据
 type RasterImage struct {
-	Width    int    `xml:"width,attr"`
-	Height   int    `xml:"height,attr"`
-	Align    string `xml:"align,attr"`
-	Content  []byte `xml:",chardata"` // 图片数据
-	filename string // 可选的文件名，用于保存图片时使用
+	Width    int         `xml:"width,attr"`
+	Height   int         `xml:"height,attr"`
+	Align    string      `xml:"align,attr"`
+	Color    string      `xml:"color,attr"` // 打印颜色，color_1为黑色，color_2为红色（双色打印机）
+	Content  []byte      `xml:",chardata"`  // 图片数据
+	Bands    []ColorBand `xml:"-"`          // 合并的图像中颜色不同的各段，为空时整个图像按Color打印
+	filename string      // 可选的文件名，用于保存图片时使用
 }
 
 func NewRasterImage(width, height int) *RasterImage {
@@ -28,6 +30,16 @@ func NewRasterImage(width, height int) *RasterImage {
 	}
 }
 
+// Clone 返回图像的完整副本，打印机会修改传入的图像（如添加边距），重复打印同一图像前需要复制
+func (img *RasterImage) Clone() *RasterImage {
+	if img == nil {
+		return nil
+	}
+	clone := *img
+	clone.Content = append([]byte(nil), img.Content...)
+	return &clone
+}
+
 func (img *RasterImage) String() string {
 	if img == nil {
 		return "RasterImage(nil)"
@@ -49,6 +49,13 @@ func (img *RasterImage) AddMarginTop(margin int) {
 	// 将原内容拷贝到新内容的下方，前面部分自动为0（空白）
 	copy(newContent[margin*img.Width/8:], img.Content)
 	img.Content = newContent
+	if len(img.Bands) > 0 {
+		bands := make([]ColorBand, len(img.Bands))
+		for i, band := range img.Bands {
+			bands[i] = ColorBand{Y: band.Y + margin, Height: band.Height, Color: band.Color}
+		}
+		img.Bands = bands
+	}
 }
 
 func (img *RasterImage) AddMarginBottom(margin int) *RasterImage {
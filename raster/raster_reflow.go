@@ -0,0 +1,122 @@
+package raster
+
+import "image"
+
+// WithReflow 把按宽纸排版的图像重新排到width宽：行之间以空白行分隔，行内以空白列分词，
+// 比width宽的行按词重新换行，只是右侧超出的行整体向左移动，单个词仍然比width宽时按method缩小。
+// 宽度向下取整为8的倍数，图像不比width宽时返回原图像
+func (img *RasterImage) WithReflow(width int, method string) *RasterImage {
+	width &^= 7
+	if img == nil || img.Content == nil || width <= 0 || img.Width <= width {
+		return img
+	}
+	var rows []*RasterImage
+	for y := 0; y < img.Height; {
+		if blank := img.blankRows(y); blank > 0 {
+			rows = append(rows, NewRasterImage(width, blank))
+			y += blank
+			continue
+		}
+		end := y + 1
+		for end < img.Height && img.blankRows(end) == 0 {
+			end++
+		}
+		rows = append(rows, img.reflowLine(y, end, width, method)...)
+		y = end
+	}
+	result := stackRows(width, rows)
+	result.Align = img.Align
+	result.Color = img.Color
+	return result
+}
+
+// reflowLine 把第y0到y1行之间的一行文字排到width宽，返回排好的各行
+func (img *RasterImage) reflowLine(y0, y1, width int, method string) []*RasterImage {
+	height := y1 - y0
+	gap := max(height/4, 4) // 词之间至少有这么多空白列，换行后行之间也留同样的空白
+	words := img.words(y0, y1, gap)
+	left, right := words[0].Min.X, words[len(words)-1].Max.X
+	if right <= width {
+		return []*RasterImage{img.WithCrop(image.Rect(0, y0, width, y1))}
+	}
+	if right-left <= width {
+		return []*RasterImage{img.WithCrop(image.Rect(right-width, y0, right, y1))}
+	}
+
+	var rows []*RasterImage
+	row := NewRasterImage(width, height)
+	x := 0
+	for i, area := range words {
+		word, wordWidth := img.WithCrop(area), area.Dx()
+		if wordWidth > width {
+			word, wordWidth = word.WithScaleWidth(width, method), width
+		}
+		space := 0
+		if x > 0 {
+			space = min(area.Min.X-words[i-1].Max.X, 3*gap) // 原来的空白，太宽时缩小，如价格前的空白
+		}
+		if x > 0 && x+space+wordWidth > width {
+			rows = append(rows, row, NewRasterImage(width, gap))
+			row = NewRasterImage(width, height)
+			x, space = 0, 0
+		}
+		row.drawBlack(word, x+space, 0)
+		x += space + wordWidth
+	}
+	return append(rows, row)
+}
+
+// words 返回第y0到y1行之间的各个词的区域，连续gap列以上的空白把词分开
+func (img *RasterImage) words(y0, y1, gap int) []image.Rectangle {
+	blank := func(x int) bool {
+		for y := y0; y < y1; y++ {
+			if img.GetPixel(x, y) == 1 {
+				return false
+			}
+		}
+		return true
+	}
+	var words []image.Rectangle
+	for x := 0; x < img.Width; x++ {
+		if blank(x) {
+			continue
+		}
+		start, end := x, x+1
+		for n := 0; x+1 < img.Width && n < gap; {
+			x++
+			if blank(x) {
+				n++
+			} else {
+				n, end = 0, x+1
+			}
+		}
+		words = append(words, image.Rect(start, y0, end, y1))
+		x = end
+	}
+	return words
+}
+
+// drawBlack 把other的黑点画到(x, y)开始的位置，超出图像的部分忽略
+func (img *RasterImage) drawBlack(other *RasterImage, x, y int) {
+	for dy := range other.Height {
+		for dx := range other.Width {
+			if other.GetPixel(dx, dy) == 1 && x+dx < img.Width && y+dy < img.Height {
+				img.SetPixelBlack(x+dx, y+dy)
+			}
+		}
+	}
+}
+
+// stackRows 把宽度为width的各行图像从上到下拼成一张图像
+func stackRows(width int, rows []*RasterImage) *RasterImage {
+	height := 0
+	for _, row := range rows {
+		height += row.Height
+	}
+	result := NewRasterImage(width, height)
+	offset := 0
+	for _, row := range rows {
+		offset += copy(result.Content[offset:], row.Content)
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package raster
+
+import "testing"
+
+// fillRect 把(x, y)开始宽w高h的区域涂黑
+func fillRect(img *RasterImage, x, y, w, h int) {
+	for dy := range h {
+		for dx := range w {
+			img.SetPixelBlack(x+dx, y+dy)
+		}
+	}
+}
+
+// blackIn 返回区域中黑点的个数
+func blackIn(img *RasterImage, x, y, w, h int) int {
+	n := 0
+	for dy := range h {
+		for dx := range w {
+			n += img.GetPixel(x+dx, y+dy)
+		}
+	}
+	return n
+}
+
+func TestWithReflow(t *testing.T) {
+	// 两行文字：第一行两个词，第二行只在右侧超出纸张
+	img := NewRasterImage(64, 26)
+	fillRect(img, 0, 0, 12, 8)
+	fillRect(img, 40, 0, 12, 8)
+	fillRect(img, 24, 18, 24, 8)
+
+	got := img.WithReflow(32, ResampleArea)
+	if got.Width != 32 || got.Height != 8+4+8+10+8 {
+		t.Fatalf("reflowed to %dx%d, want 32x38", got.Width, got.Height)
+	}
+	tests := []struct {
+		name       string
+		x, y, w, h int
+		want       int
+	}{
+		{"first word", 0, 0, 12, 8, 96},
+		{"second word wrapped", 0, 12, 12, 8, 96},
+		{"nothing beside the first word", 12, 0, 20, 8, 0},
+		{"second line moved left", 8, 30, 24, 8, 192},
+	}
+	for _, tt := range tests {
+		if n := blackIn(got, tt.x, tt.y, tt.w, tt.h); n != tt.want {
+			t.Errorf("%s: %d black dots, want %d", tt.name, n, tt.want)
+		}
+	}
+}
+
+func TestWithReflowLongWord(t *testing.T) {
+	img := NewRasterImage(64, 8)
+	fillRect(img, 0, 0, 64, 8)
+
+	got := img.WithReflow(32, ResampleArea)
+	if got.Width != 32 || got.Height != 8 || blackIn(got, 0, 0, 32, 4) != 128 {
+		t.Errorf("reflowed to %v, want the word shrunk to 32x4", got)
+	}
+	if narrow := NewRasterImage(32, 8); narrow.WithReflow(32, ResampleArea) != narrow {
+		t.Error("image that fits the paper was changed")
+	}
+}
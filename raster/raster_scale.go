@@ -0,0 +1,115 @@
+package raster
+
+import (
+	"math"
+	"strings"
+)
+
+// 缩放图像时的重采样方式
+const (
+	ResampleNearest = "nearest" // 最近邻，速度快，缩小时细线可能断开
+	ResampleArea    = "area"    // 区域平均，按黑点覆盖的面积重新二值化，缩小后文字更完整
+)
+
+// WithScaleWidth 按比例缩放图像到指定宽度（向上取整为8的倍数），高度按同样比例缩放。
+// method为ResampleNearest或ResampleArea（默认），区域平均时黑点覆盖一半以上的点为黑色
+func (img *RasterImage) WithScaleWidth(width int, method string) *RasterImage {
+	if img == nil || img.Width <= 0 || img.Height <= 0 || img.Content == nil || width <= 0 {
+		return img
+	}
+	width = (width + 7) &^ 7
+	height := max((img.Height*width+img.Width/2)/img.Width, 1)
+	scaled := NewRasterImage(width, height)
+	scaled.Align = img.Align
+	scaled.Color = img.Color
+	scaled.Bands = img.scaleBands(height)
+
+	sx := float64(img.Width) / float64(width)
+	sy := float64(img.Height) / float64(height)
+	for y := range height {
+		for x := range width {
+			var black bool
+			if method == ResampleNearest {
+				black = img.GetPixel(int((float64(x)+0.5)*sx), int((float64(y)+0.5)*sy)) == 1
+			} else {
+				black = img.coverage(float64(x)*sx, float64(y)*sy, sx, sy) >= 0.5
+			}
+			if black {
+				scaled.SetPixelBlack(x, y)
+			}
+		}
+	}
+	return scaled
+}
+
+// coverage 返回从(x0, y0)开始宽w高h的区域中黑点覆盖的比例，区域边缘的点按覆盖的面积计算
+func (img *RasterImage) coverage(x0, y0, w, h float64) float64 {
+	x1, y1 := x0+w, y0+h
+	var black float64
+	for y := int(y0); y < int(math.Ceil(y1)) && y < img.Height; y++ {
+		dy := math.Min(y1, float64(y+1)) - math.Max(y0, float64(y))
+		for x := int(x0); x < int(math.Ceil(x1)) && x < img.Width; x++ {
+			if img.GetPixel(x, y) == 1 {
+				black += dy * (math.Min(x1, float64(x+1)) - math.Max(x0, float64(x)))
+			}
+		}
+	}
+	return black / (w * h)
+}
+
+// WithCropWidth 按对齐方式裁剪到指定宽度：左对齐保留左侧，右对齐保留右侧，其他保留中间。
+// 宽度向下取整为8的倍数，图像不比指定宽度宽时返回原图像
+func (img *RasterImage) WithCropWidth(width int) *RasterImage {
+	width &^= 7
+	if img == nil || img.Content == nil || width <= 0 || img.Width <= width {
+		return img
+	}
+	var offset int
+	switch strings.ToLower(img.Align) {
+	case "left":
+	case "right":
+		offset = img.Width - width
+	default:
+		offset = (img.Width - width) / 2
+	}
+	return img.cropBytes(offset/8, width/8)
+}
+
+// WithTrimMarginX 去掉左右两侧的空白列，按字节裁剪，全白的图像返回原图像
+func (img *RasterImage) WithTrimMarginX() *RasterImage {
+	if img == nil || img.Width <= 0 || img.Content == nil {
+		return img
+	}
+	rowBytes := img.Width / 8
+	left, right := rowBytes, 0
+	for y := range img.Height {
+		row := img.Content[y*rowBytes : (y+1)*rowBytes]
+		for i, b := range row {
+			if b != 0 {
+				left = min(left, i)
+				right = max(right, i+1)
+			}
+		}
+	}
+	if left >= right || right-left == rowBytes {
+		return img
+	}
+	return img.cropBytes(left, right-left)
+}
+
+// cropBytes 返回每行从第offset字节开始、宽n字节的图像
+func (img *RasterImage) cropBytes(offset, n int) *RasterImage {
+	rowBytes := img.Width / 8
+	content := make([]byte, img.Height*n)
+	for y := range img.Height {
+		copy(content[y*n:(y+1)*n], img.Content[y*rowBytes+offset:])
+	}
+	return &RasterImage{
+		Width:   n * 8,
+		Height:  img.Height,
+		Align:   img.Align,
+		Color:   img.Color,
+		Bands:   img.Bands,
+		Content: content,
+	}
+}
@@ -0,0 +1,50 @@
+package raster
+
+import "bytes"
+
+// Star Line Mode 光栅指令
+var (
+	starRasterEnter = []byte{0x1B, '*', 'r', 'A'}            // ESC * r A 进入光栅模式
+	starRasterPage  = []byte{0x1B, '*', 'r', 'P', '0', 0x00} // ESC * r P 0 NUL 连续纸，不按页长走纸
+	starRasterQuit  = []byte{0x1B, '*', 'r', 'B'}            // ESC * r B 退出光栅模式
+)
+
+// ToStarRasterCommand 生成Star Line Mode的光栅打印指令，
+// 进入光栅模式后每行使用 b n1 n2 d1...dk 传送，最后退出光栅模式，切纸由调用方在退出后发送
+func (img *RasterImage) ToStarRasterCommand() []byte {
+	return img.starRaster(false)
+}
+
+// ToStarCompactRasterCommand 与ToStarRasterCommand相同，但每行去掉右侧的空白字节，
+// 连续的空白行用 ESC * r Y n NUL 走纸代替
+func (img *RasterImage) ToStarCompactRasterCommand() []byte {
+	return img.starRaster(true)
+}
+
+func (img *RasterImage) starRaster(compact bool) []byte {
+	if img == nil || img.Width <= 0 || img.Height <= 0 || img.Content == nil {
+		return nil
+	}
+	widthBytes := img.Width / 8
+	result := make([]byte, 0, 32+len(img.Content)+3*img.Height)
+	result = append(result, starRasterEnter...)
+	result = append(result, starRasterPage...)
+	blank := 0
+	for y := 0; y < img.Height; y++ {
+		row := img.Content[y*widthBytes : (y+1)*widthBytes]
+		if compact {
+			row = bytes.TrimRight(row, "\x00")
+			if len(row) == 0 {
+				blank++
+				continue
+			}
+			result = append(result, starFeed(blank)...)
+			blank = 0
+		}
+		n1, n2 := LowHighValue(len(row))
+		result = append(result, 'b', n1, n2)
+		result = append(result, row...)
+	}
+	result = append(result, starFeed(blank)...)
+	return append(result, starRasterQuit...)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/xiaohao0576/odoo-epos/hwproxy"
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+)
+
+// PrinterStatusResult 打印机状态查询结果
+type PrinterStatusResult struct {
+	Success bool                    `json:"success"`
+	Msg     string                  `json:"msg"`
+	Status  *eprinter.PrinterStatus `json:"status,omitempty"`
+}
+
+// ePrintStatusHandler 查询打印机实时状态
+// GET /eprint/status?x_printer=p1 返回一台打印机的状态
+// GET /eprint/status 同时查询所有打印机
+func ePrintStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Private-Network", "true")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"success":false,"msg":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	printerName := r.URL.Query().Get("x_printer")
+	if printerName != "" {
+		printer, ok := GetPrinters()[printerName]
+		if !ok {
+			http.Error(w, `{"success":false,"msg":"Printer not found"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(queryPrinterStatus(printer))
+		return
+	}
+
+	// 查询可能需要等待超时，所有打印机同时查询
+	printers := GetPrinters()
+	results := make(map[string]PrinterStatusResult, len(printers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, printer := range printers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := queryPrinterStatus(printer)
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "printers": results})
+}
+
+// queryPrinterStatus 查询打印机状态，打印机可以正常打印时success为true
+func queryPrinterStatus(printer eprinter.EPrinter) PrinterStatusResult {
+	status, err := eprinter.Status(printer)
+	if err != nil {
+		return PrinterStatusResult{Success: false, Msg: err.Error()}
+	}
+	return PrinterStatusResult{Success: status.Err() == nil, Msg: status.String(), Status: &status}
+}
+
+// newHwProxy 返回Odoo IoT Box的hw_proxy接口，/hw_proxy/status_json报告默认打印机的实时状态
+func newHwProxy() *hwproxy.HwProxy {
+	return &hwproxy.HwProxy{Printer: defaultPrinter}
+}
+
+// defaultPrinter 返回名称排序第一的打印机，没有打印机时返回nil
+func defaultPrinter() eprinter.EPrinter {
+	printers := GetPrinters()
+	names := make([]string, 0, len(printers))
+	for name := range printers {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return printers[slices.Min(names)]
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	eprinter "github.com/xiaohao0576/odoo-epos/printer"
+	"github.com/xiaohao0576/odoo-epos/printer/printertest"
+)
+
+func getStatus(t *testing.T, url string, v any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	ePrintStatusHandler(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: invalid response %q: %v", url, w.Body, err)
+		}
+	}
+	return w.Code
+}
+
+func TestStatusHandler(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	setTestPrinters(t, s)
+
+	var result PrinterStatusResult
+	if code := getStatus(t, "/eprint/status?x_printer=p1", &result); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if !result.Success || result.Status == nil || !result.Status.Online {
+		t.Errorf("online printer: %+v, want success", result)
+	}
+
+	s.SetStatus(eprinter.PrinterStatus{Online: true, CoverOpen: true})
+	result = PrinterStatusResult{}
+	getStatus(t, "/eprint/status?x_printer=p1", &result)
+	if result.Success || result.Status == nil || !result.Status.CoverOpen {
+		t.Errorf("cover open: %+v, want failure with cover_open", result)
+	}
+}
+
+func TestStatusHandlerAllPrinters(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	setTestPrinters(t, s)
+	s.SetStatus(eprinter.PrinterStatus{Online: true, PaperEnd: true})
+
+	var result struct {
+		Success  bool                           `json:"success"`
+		Printers map[string]PrinterStatusResult `json:"printers"`
+	}
+	if code := getStatus(t, "/eprint/status", &result); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	p1, ok := result.Printers["p1"]
+	if !result.Success || !ok || p1.Success || p1.Status == nil || !p1.Status.PaperEnd {
+		t.Errorf("response %+v, want p1 with paper end", result)
+	}
+}
+
+func TestStatusHandlerPrinterNotFound(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	setTestPrinters(t, s)
+
+	var result PrinterStatusResult
+	if code := getStatus(t, "/eprint/status?x_printer=p2", &result); code != http.StatusBadRequest {
+		t.Errorf("status %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestHwProxyStatus(t *testing.T) {
+	s := printertest.NewServer()
+	defer s.Close()
+	setTestPrinters(t, s)
+	s.SetStatus(eprinter.PrinterStatus{Online: true, PaperEnd: true})
+
+	w := httptest.NewRecorder()
+	newHwProxy().NewMux().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hw_proxy/status_json", strings.NewReader("{}")))
+	var result struct {
+		Printer map[string]string `json:"printer"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body, err)
+	}
+	if result.Printer["status"] != "error" {
+		t.Errorf("printer %v, want status error for paper end", result.Printer)
+	}
+}
@@ -166,7 +166,7 @@ func tsplhandler01(w http.ResponseWriter, r *http.Request) {
 	tsplData := labels.ToTSPL()
 
 	// 获取打印机名称
-	printer, ok := Printers[printerName]
+	printer, ok := GetPrinters()[printerName]
 	if !ok {
 		http.Error(w, `{"success":false,"msg":"Printer not found"}`, http.StatusBadRequest)
 		return